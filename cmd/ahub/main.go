@@ -1,15 +1,27 @@
 package main
 
 import (
+	"ahub/internal/audit"
 	"ahub/internal/auth"
 	"ahub/internal/config"
+	"ahub/internal/email"
+	"ahub/internal/events"
+	"ahub/internal/identifier"
 	"ahub/internal/migrations"
+	"ahub/internal/notify"
+	"ahub/internal/password"
+	"ahub/internal/ratelimit"
+	"ahub/internal/requestctx"
+	"ahub/internal/secretbox"
 	storagebd "ahub/storage"
+	"context"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 const (
@@ -35,26 +47,150 @@ func main() {
 
 	storage, err := storagebd.New(cfg, log)
 	if err != nil {
-		log.Error("failed to initialize storage:", err)
+		log.Error("failed to initialize storage", slog.String("error", err.Error()))
 		return
 	}
 
 	authStorage := auth.NewStorage(storage)
 
-	jwtManager := auth.NewJWTManager(cfg.JWT.Secret, cfg.JWTTTLDuration())
+	jwtManager := auth.NewJWTManager(cfg.JWT.Secret, cfg.JWTTTLDuration(), cfg.JWT.StepUpTTLDuration(), cfg.JWT.ImpersonationTTLDuration())
 
-	authService := auth.NewAuthService(authStorage, cfg.Redis.TTLDuration(), jwtManager)
+	sender := setupSender(cfg, log)
+
+	normalizer := identifier.NewNormalizer(cfg.Login.DefaultRegion)
+
+	// телефоны, сохранённые до нормализации логинов, иначе не найдутся при входе
+	backfill, err := storage.Postgres.NormalizePhones(context.Background(), normalizer.Phone)
+	if err != nil {
+		log.Error("failed to normalize phones", slog.String("error", err.Error()))
+		return
+	}
+	if backfill.Updated+backfill.Conflicts+backfill.Invalid > 0 {
+		log.Info("phones normalized",
+			slog.Int("updated", backfill.Updated),
+			slog.Int("conflicts", backfill.Conflicts),
+			slog.Int("invalid", backfill.Invalid),
+		)
+	}
+
+	policy, err := setupPasswordPolicy(cfg.Password)
+	if err != nil {
+		log.Error("failed to initialize password policy", slog.String("error", err.Error()))
+		return
+	}
+
+	argon, err := password.NewHasher(cfg.Password.Argon2)
+	if err != nil {
+		log.Error("invalid argon2 parameters", slog.String("error", err.Error()))
+		return
+	}
+	hasher := password.NewPool(argon, cfg.Password.HashPool)
+
+	lockout := auth.NewLockout(storage.Redis.Client, cfg.Lockout)
+
+	if cfg.MFA.EncryptionKey == "" {
+		log.Error("MFA_ENCRYPTION_KEY is required")
+		return
+	}
+
+	box, err := secretbox.New(cfg.MFA.EncryptionKey)
+	if err != nil {
+		log.Error("failed to initialize mfa encryption", slog.String("error", err.Error()))
+		return
+	}
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+	})
+	if err != nil {
+		log.Error("failed to initialize webauthn", slog.String("error", err.Error()))
+		return
+	}
+
+	if cfg.MFA.DeviceSecret == "" {
+		log.Warn("MFA_DEVICE_SECRET is not set, trusted devices are disabled")
+	}
+
+	if cfg.MagicLink.Enabled && cfg.MagicLink.Secret == "" {
+		log.Error("MAGIC_LINK_SECRET is required when magic links are enabled")
+		return
+	}
+
+	auditLog := audit.New(storage.Postgres, log)
+	go auditLog.Run(context.Background())
+
+	authService := auth.NewAuthService(authStorage, jwtManager, sender, normalizer, policy, hasher, lockout, box, wa, auditLog, auth.Options{
+		OTPTTL:          cfg.Redis.TTLDuration(),
+		OTPLogin:        cfg.Login.OTPEnabled,
+		DeletionGrace:   cfg.Account.DeletionGraceDuration(),
+		MFAIssuer:       cfg.MFA.Issuer,
+		MFAChallengeTTL: cfg.MFA.ChallengeTTLDuration(),
+		DeviceSecret:    cfg.MFA.DeviceSecret,
+		DeviceTTL:       cfg.MFA.DeviceTTLDuration(),
+		WebAuthnTTL:     cfg.WebAuthn.ChallengeTTLDuration(),
+		MagicLink:       cfg.MagicLink,
+		LoginAlerts:     cfg.LoginAlerts,
+		StatusCacheTTL:  cfg.Account.StatusCacheTTLDuration(),
+	})
 	authHandler := auth.NewHandler(authService)
 
+	purger := auth.NewPurger(authStorage, auditLog, cfg.Account.PurgeIntervalDuration(), cfg.Account.ActivityRetentionDuration(), cfg.Account.AuditRetentionDuration(), log)
+	go purger.Run(context.Background())
+
+	dispatcher := events.NewDispatcher(storage.Postgres, cfg.Webhooks, nil, log)
+	go dispatcher.Run(context.Background())
+
 	r := gin.Default()
+	// без этого gin верит X-Forwarded-For от любого клиента, и блокировки по IP обходятся подменой заголовка
+	if err := r.SetTrustedProxies(cfg.HTTPServer.TrustedProxies); err != nil {
+		log.Error("invalid trusted proxies", slog.String("error", err.Error()))
+		return
+	}
+	r.Use(requestctx.Middleware())
+
+	limiter := ratelimit.New(storage.Redis.Client, normalizer, cfg.RateLimit, log)
+
+	auth.RegisterRoutes(r, authHandler, jwtManager, cfg.Admin.APIKey, cfg.JWT.RecentAuthMaxAgeDuration(), limiter.Middleware())
 
-	auth.RegisterRoutes(r, authHandler, jwtManager)
+	adminAuth := auth.AdminAuth(jwtManager, authService, cfg.Admin.APIKey)
+	audit.RegisterRoutes(r, audit.NewHandler(auditLog), adminAuth, auth.RequirePermission(auth.PermAuditRead))
+	events.RegisterRoutes(r, events.NewHandler(storage.Postgres), adminAuth, auth.RequirePermission(auth.PermWebhooksRead))
+
+	// счётчики раскрывают нагрузку и состояние очередей, поэтому доступны только администраторам
+	r.GET("/debug/vars", adminAuth, auth.RequirePermission(auth.PermMetricsRead), gin.WrapH(expvar.Handler()))
 
 	if err := r.Run(cfg.HTTPServer.Address); err != nil {
-		log.Error("failed to run server:", err)
+		log.Error("failed to run server", slog.String("error", err.Error()))
 	}
 }
 
+func setupSender(cfg *config.Config, log *slog.Logger) notify.Sender {
+	logSender := &notify.LogSender{Log: log}
+
+	var emailSender notify.Sender = logSender
+	if cfg.SMTP.Host != "" {
+		emailSender = email.NewSender(cfg.SMTP)
+	}
+
+	// SMS-провайдера пока нет, коды на телефон уходят в лог
+	return &notify.Router{Email: emailSender, SMS: logSender}
+}
+
+func setupPasswordPolicy(cfg config.PasswordConfig) (*password.Policy, error) {
+	if cfg.BreachedDir == "" {
+		return password.NewPolicy(cfg, nil), nil
+	}
+
+	breached, err := password.NewBreachedList(cfg.BreachedDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return password.NewPolicy(cfg, breached), nil
+}
+
 func setupLogger(env string) *slog.Logger {
 	var handler slog.Handler
 
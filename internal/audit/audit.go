@@ -0,0 +1,442 @@
+package audit
+
+import (
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actor для действий, которые совершает не пользователь.
+const (
+	ActorSystem = "system"
+	ActorAdmin  = "admin"
+)
+
+const (
+	ActionRegistered          = "user.registered"
+	ActionLogin               = "user.login"
+	ActionLoginFailed         = "user.login_failed"
+	ActionLogout              = "user.logout"
+	ActionDeleted             = "user.deleted"
+	ActionContactChanged      = "user.contact_changed"
+	ActionTokenRotated        = "session.token_rotated"
+	ActionSessionRevoked      = "session.revoked"
+	ActionPasswordChanged     = "password.changed"
+	ActionPasswordReset       = "password.reset"
+	ActionTOTPEnabled         = "mfa.totp_enabled"
+	ActionTOTPDisabled        = "mfa.totp_disabled"
+	ActionRecoveryCodesIssued = "mfa.recovery_codes_issued"
+	ActionWebAuthnRegistered  = "mfa.webauthn_registered"
+	ActionUsersListed         = "admin.users_listed"
+	ActionUserViewed          = "admin.user_viewed"
+	ActionUserUnlocked        = "admin.user_unlocked"
+	ActionRoleGranted         = "admin.role_granted"
+	ActionRoleRevoked         = "admin.role_revoked"
+	ActionUserDisabled        = "admin.user_disabled"
+	ActionUserEnabled         = "admin.user_enabled"
+	ActionUserLocked          = "admin.user_locked"
+	ActionUserRestored        = "admin.user_restored"
+	ActionPasswordResetForced = "admin.password_reset_forced"
+	ActionSessionsRevoked     = "admin.sessions_revoked"
+	ActionImpersonated        = "admin.impersonation_started"
+	ActionImpersonatedRequest = "admin.impersonated_request"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+	verifyBatch  = 1000
+
+	queueSize    = 1024
+	writeBatch   = 100
+	writeTimeout = 10 * time.Second
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Event — то, что сообщает сервис; IP, request ID и время журнал добавляет сам,
+// а для запросов по токену имперсонации — ещё и impersonator в метаданные.
+type Event struct {
+	Actor    string
+	Subject  string
+	Action   string
+	Metadata map[string]any
+}
+
+type Entry struct {
+	Seq       int64           `json:"seq"`
+	ID        string          `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	Subject   string          `json:"subject,omitempty"`
+	Action    string          `json:"action"`
+	IP        string          `json:"ip,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Metadata  json.RawMessage `json:"metadata"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+type Filter struct {
+	Actor   string
+	Subject string
+	Action  string
+	From    time.Time
+	To      time.Time
+	Cursor  string
+	Limit   int
+}
+
+type Page struct {
+	Entries    []Entry `json:"entries"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// VerifyResult: BrokenSeq — первая запись, у которой не сходится хеш или ссылка на предыдущую;
+// FromSeq — последняя запись, удалённая по сроку хранения, проверка начинается после неё.
+type VerifyResult struct {
+	Valid     bool  `json:"valid"`
+	Checked   int64 `json:"checked"`
+	FromSeq   int64 `json:"from_seq,omitempty"`
+	BrokenSeq int64 `json:"broken_seq,omitempty"`
+}
+
+// Log — журнал аудита. Каждая запись хранит хеш предыдущей, поэтому изменение или удаление
+// любой строки в обход приложения обнаруживается при проверке цепочки.
+//
+// Пользователи в журнале обозначены только UUID: после окончательного удаления аккаунта
+// их не с кем связать. IP и метаданные хранятся не дольше срока хранения (Prune).
+//
+// Записи дописывает одна горутина (Run) пачками, под одной блокировкой цепочки на пачку,
+// поэтому запись аудита не выстраивает входы в очередь друг за другом.
+type Log struct {
+	storage *postgres.Storage
+	queue   chan pendingEntry
+	log     *slog.Logger
+}
+
+// pendingEntry: done == nil — никто не ждёт результата, ошибка только пишется в лог.
+type pendingEntry struct {
+	entry *postgres.AuditEntry
+	done  chan error
+}
+
+func New(storage *postgres.Storage, log *slog.Logger) *Log {
+	return &Log{
+		storage: storage,
+		queue:   make(chan pendingEntry, queueSize),
+		log:     log,
+	}
+}
+
+// Record ждёт, пока запись окажется в цепочке: для действий, которые нельзя выполнить без аудита.
+func (l *Log) Record(ctx context.Context, e Event) error {
+	entry, err := newEntry(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+
+	select {
+	case l.queue <- pendingEntry{entry: entry, done: done}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RecordAsync ставит запись в очередь и не ждёт её сохранения. Если очередь заполнена,
+// запись сохраняется сразу: события аудита не теряются.
+func (l *Log) RecordAsync(ctx context.Context, e Event) error {
+	entry, err := newEntry(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case l.queue <- pendingEntry{entry: entry}:
+		return nil
+	default:
+		return l.append(context.WithoutCancel(ctx), []*postgres.AuditEntry{entry})
+	}
+}
+
+// Run дописывает записи из очереди, пока не отменён ctx; оставшиеся в очереди сохраняются перед выходом.
+func (l *Log) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			l.drain()
+			return
+		case p := <-l.queue:
+			l.write(l.collect(p))
+		}
+	}
+}
+
+// collect добавляет к первой записи всё, что уже накопилось в очереди.
+func (l *Log) collect(first pendingEntry) []pendingEntry {
+	batch := []pendingEntry{first}
+
+	for len(batch) < writeBatch {
+		select {
+		case p := <-l.queue:
+			batch = append(batch, p)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+func (l *Log) drain() {
+	for {
+		select {
+		case p := <-l.queue:
+			l.write(l.collect(p))
+		default:
+			return
+		}
+	}
+}
+
+func (l *Log) write(batch []pendingEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	entries := make([]*postgres.AuditEntry, len(batch))
+	for i, p := range batch {
+		entries[i] = p.entry
+	}
+
+	err := l.append(ctx, entries)
+
+	for _, p := range batch {
+		if p.done != nil {
+			p.done <- err
+		} else if err != nil {
+			l.log.Error("record audit event", slog.String("action", p.entry.Action), slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (l *Log) append(ctx context.Context, entries []*postgres.AuditEntry) error {
+	return l.storage.AppendAuditEntries(ctx, entries, entryHash)
+}
+
+func newEntry(ctx context.Context, e Event) (*postgres.AuditEntry, error) {
+	info := requestctx.From(ctx)
+
+	if info.Impersonator != "" {
+		// копия, чтобы не менять карту вызывающего
+		m := make(map[string]any, len(e.Metadata)+1)
+		maps.Copy(m, e.Metadata)
+		m["impersonator"] = info.Impersonator
+		e.Metadata = m
+	}
+
+	metadata, err := canonicalMetadata(e.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgres.AuditEntry{
+		ID:        uuid.NewString(),
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Actor:     e.Actor,
+		Subject:   e.Subject,
+		Action:    e.Action,
+		IP:        info.IP,
+		RequestID: info.RequestID,
+		Metadata:  metadata,
+	}, nil
+}
+
+// Prune удаляет записи старше before с начала цепочки; хеш последней удалённой записи
+// сохраняется, и Verify продолжает проверку с него.
+func (l *Log) Prune(ctx context.Context, before time.Time) (int64, error) {
+	return l.storage.PruneAuditEntries(ctx, before)
+}
+
+// Query отдаёт записи от новых к старым; пустой Cursor — первая страница.
+func (l *Log) Query(ctx context.Context, f Filter) (*Page, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	limit = min(limit, maxLimit)
+
+	filter := postgres.AuditFilter{
+		Actor:   f.Actor,
+		Subject: f.Subject,
+		Action:  f.Action,
+		Limit:   limit + 1,
+	}
+	if !f.From.IsZero() {
+		filter.From = &f.From
+	}
+	if !f.To.IsZero() {
+		filter.To = &f.To
+	}
+	if f.Cursor != "" {
+		seq, err := strconv.ParseInt(f.Cursor, 10, 64)
+		if err != nil || seq <= 0 {
+			return nil, ErrInvalidCursor
+		}
+		filter.BeforeSeq = seq
+	}
+
+	rows, err := l.storage.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Entries: make([]Entry, 0, min(len(rows), limit))}
+	for i, row := range rows {
+		if i == limit {
+			page.NextCursor = strconv.FormatInt(rows[i-1].Seq, 10)
+			break
+		}
+		page.Entries = append(page.Entries, toEntry(row))
+	}
+
+	return page, nil
+}
+
+// Verify проходит всю цепочку с начала и пересчитывает хеши.
+func (l *Log) Verify(ctx context.Context) (*VerifyResult, error) {
+	result := &VerifyResult{Valid: true}
+
+	var afterSeq int64
+	prevHash := ""
+
+	cp, err := l.storage.LatestAuditCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cp != nil {
+		afterSeq, prevHash = cp.Seq, cp.Hash
+		result.FromSeq = cp.Seq
+	}
+
+	for {
+		rows, err := l.storage.ScanAuditEntries(ctx, afterSeq, verifyBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range rows {
+			row := &rows[i]
+			if row.PrevHash != prevHash || row.Hash != entryHash(prevHash, row) {
+				result.Valid = false
+				result.BrokenSeq = row.Seq
+				return result, nil
+			}
+			prevHash = row.Hash
+			afterSeq = row.Seq
+			result.Checked++
+		}
+
+		if len(rows) < verifyBatch {
+			return result, nil
+		}
+	}
+}
+
+func toEntry(row postgres.AuditEntry) Entry {
+	return Entry{
+		Seq:       row.Seq,
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt,
+		Actor:     row.Actor,
+		Subject:   row.Subject,
+		Action:    row.Action,
+		IP:        row.IP,
+		RequestID: row.RequestID,
+		Metadata:  json.RawMessage(row.Metadata),
+		PrevHash:  row.PrevHash,
+		Hash:      row.Hash,
+	}
+}
+
+// entryHash покрывает все поля записи и хеш предыдущей. Метаданные берутся в каноническом виде:
+// JSONB не сохраняет исходный порядок ключей и пробелы.
+func entryHash(prevHash string, e *postgres.AuditEntry) string {
+	metadata, err := canonicalJSON(e.Metadata)
+	if err != nil {
+		metadata = e.Metadata
+	}
+
+	fields := []string{
+		prevHash,
+		e.ID,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.Actor,
+		e.Subject,
+		e.Action,
+		e.IP,
+		e.RequestID,
+		metadata,
+	}
+
+	h := sha256.New()
+	for _, f := range fields {
+		// длина перед значением, чтобы границы полей нельзя было сдвинуть
+		h.Write([]byte(strconv.Itoa(len(f)) + ":" + f + "\n"))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func canonicalMetadata(m map[string]any) (string, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return canonicalJSON(string(raw))
+}
+
+// canonicalJSON: ключи объектов по алфавиту (так их пишет encoding/json), числа без изменений.
+func canonicalJSON(raw string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueryRequest struct {
+	Actor   string    `form:"actor" binding:"max=64"`
+	Subject string    `form:"subject" binding:"max=64"`
+	Action  string    `form:"action" binding:"max=64"`
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor  string    `form:"cursor" binding:"max=32"`
+	Limit   int       `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+type Handler struct {
+	log *Log
+}
+
+func NewHandler(log *Log) *Handler {
+	return &Handler{log: log}
+}
+
+func (h *Handler) Query(c *gin.Context) {
+	var req QueryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	page, err := h.log.Query(ctx, Filter{
+		Actor:   req.Actor,
+		Subject: req.Subject,
+		Action:  req.Action,
+		From:    req.From,
+		To:      req.To,
+		Cursor:  req.Cursor,
+		Limit:   req.Limit,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Verify проверяет цепочку целиком, поэтому таймаут больше обычного.
+func (h *Handler) Verify(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	result, err := h.log.Verify(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RegisterRoutes: middleware — аутентификация и проверка прав административного API.
+func RegisterRoutes(r *gin.Engine, h *Handler, middleware ...gin.HandlerFunc) {
+	admin := r.Group("/admin/audit")
+	admin.Use(middleware...)
+	{
+		admin.GET("", h.Query)
+		admin.GET("/verify", h.Verify)
+	}
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/identifier"
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	contactEmail = string(identifier.Email)
+	contactPhone = string(identifier.Phone)
+
+	pendingContactChange = "contact_change"
+)
+
+var (
+	ErrSameContact    = errors.New("new value matches the current one")
+	ErrForeignPending = errors.New("confirmation token belongs to another user")
+	ErrIdentifierSet  = errors.New("account already has an identifier of this kind, use the change flow")
+)
+
+type ContactChangeData struct {
+	otpState
+	UserID string `json:"user_id"`
+	Kind   string `json:"kind"`
+	Value  string `json:"value"`
+}
+
+// StartContactChange отправляет код на новый адрес; сам адрес меняется только в ConfirmContactChange.
+func (s *AuthService) StartContactChange(ctx context.Context, userID, kind, value string) (string, error) {
+	var err error
+
+	switch kind {
+	case contactEmail:
+		value, err = s.ids.Email(value)
+	case contactPhone:
+		value, err = s.ids.Phone(value)
+	default:
+		err = fmt.Errorf("unknown contact kind %q", kind)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if current := contactOf(user, kind); current != nil && *current == value {
+		return "", ErrSameContact
+	}
+
+	if owner, err := s.storage.bd.Postgres.GetUserByLogin(ctx, value); err == nil && owner.ID != userID {
+		return "", contactTakenError(kind)
+	}
+
+	otp := generationOTP()
+
+	token, err := s.storage.SavePending(ctx, pendingContactChange, ContactChangeData{
+		otpState: otpState{OTP: otp},
+		UserID:   userID,
+		Kind:     kind,
+		Value:    value,
+	}, s.otpTTL)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.sender.Send(ctx, value, "Confirmation code", "Your confirmation code: "+otp); err != nil {
+		_ = s.storage.DeletePending(ctx, pendingContactChange, token)
+		return "", fmt.Errorf("send confirmation code: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *AuthService) ConfirmContactChange(ctx context.Context, userID, kind, token, code string) error {
+	var data ContactChangeData
+	if err := s.storage.GetPending(ctx, pendingContactChange, token, &data); err != nil {
+		return err
+	}
+
+	if data.UserID != userID || data.Kind != kind {
+		return ErrForeignPending
+	}
+
+	if err := s.checkCode(ctx, pendingContactChange, token, code, &data); err != nil {
+		return err
+	}
+
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case contactEmail:
+		err = s.storage.UpdateEmail(ctx, userID, data.Value)
+	case contactPhone:
+		err = s.storage.UpdatePhone(ctx, userID, data.Value)
+	}
+	if err != nil {
+		return err
+	}
+
+	_ = s.storage.DeletePending(ctx, pendingContactChange, token)
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:    userID,
+		Subject:  userID,
+		Action:   audit.ActionContactChanged,
+		Metadata: map[string]any{"kind": kind},
+	})
+
+	if old := contactOf(user, kind); old != nil && *old != "" {
+		_ = s.sender.Send(ctx, *old,
+			"Your "+kind+" was changed",
+			"The "+kind+" on your account was changed to "+data.Value+". If this wasn't you, contact support.",
+		)
+	}
+
+	return nil
+}
+
+// StartAddIdentifier привязывает второй идентификатор (email к телефону или наоборот).
+func (s *AuthService) StartAddIdentifier(ctx context.Context, userID, login string) (string, error) {
+	id, err := s.ids.Normalize(login)
+	if err != nil {
+		return "", err
+	}
+
+	kind := string(id.Kind)
+
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if contactOf(user, kind) != nil {
+		return "", ErrIdentifierSet
+	}
+
+	return s.StartContactChange(ctx, userID, kind, id.Value)
+}
+
+func (s *AuthService) ConfirmAddIdentifier(ctx context.Context, userID, token, code string) error {
+	var data ContactChangeData
+	if err := s.storage.GetPending(ctx, pendingContactChange, token, &data); err != nil {
+		return err
+	}
+
+	return s.ConfirmContactChange(ctx, userID, data.Kind, token, code)
+}
+
+func contactOf(user *postgres.User, kind string) *string {
+	if kind == contactEmail {
+		return user.Email
+	}
+	return user.Phone
+}
+
+func contactTakenError(kind string) error {
+	if kind == contactEmail {
+		return postgres.ErrEmailTaken
+	}
+	return postgres.ErrPhoneTaken
+}
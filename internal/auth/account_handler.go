@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"ahub/internal/identifier"
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChangeEmailRequest struct {
+	Email string `json:"email" binding:"required,max=254"`
+}
+
+type ChangePhoneRequest struct {
+	Phone string `json:"phone" binding:"required,max=25"`
+}
+
+type AddIdentifierRequest struct {
+	Login string `json:"login" binding:"required,max=254,login"`
+}
+
+type ChangeContactResponse struct {
+	ChangeToken string `json:"change_token"`
+}
+
+type ConfirmChangeRequest struct {
+	Token string `json:"token" binding:"required,uuid"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
+}
+
+func (h *AuthHandler) StartEmailChange(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	h.startContactChange(c, contactEmail, req.Email)
+}
+
+func (h *AuthHandler) StartPhoneChange(c *gin.Context) {
+	var req ChangePhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	h.startContactChange(c, contactPhone, req.Phone)
+}
+
+func (h *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	h.confirmContactChange(c, contactEmail)
+}
+
+func (h *AuthHandler) ConfirmPhoneChange(c *gin.Context) {
+	h.confirmContactChange(c, contactPhone)
+}
+
+func (h *AuthHandler) StartAddIdentifier(c *gin.Context) {
+	var req AddIdentifierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	token, err := h.service.StartAddIdentifier(ctx, c.GetString("user_id"), req.Login)
+	if err != nil {
+		c.JSON(contactErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ChangeContactResponse{ChangeToken: token})
+}
+
+func (h *AuthHandler) ConfirmAddIdentifier(c *gin.Context) {
+	var req ConfirmChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.ConfirmAddIdentifier(ctx, c.GetString("user_id"), req.Token, req.Code); err != nil {
+		c.JSON(contactErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "identifier added"})
+}
+
+func (h *AuthHandler) startContactChange(c *gin.Context, kind, value string) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	token, err := h.service.StartContactChange(ctx, c.GetString("user_id"), kind, value)
+	if err != nil {
+		c.JSON(contactErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ChangeContactResponse{ChangeToken: token})
+}
+
+func (h *AuthHandler) confirmContactChange(c *gin.Context, kind string) {
+	var req ConfirmChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.ConfirmContactChange(ctx, c.GetString("user_id"), kind, req.Token, req.Code); err != nil {
+		c.JSON(contactErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": kind + " updated"})
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	purgeAfter, err := h.service.DeleteAccount(ctx, c.GetString("user_id"), req.Password)
+	if err != nil {
+		if respondOverloaded(c, err) || respondThrottled(c, err) {
+			return
+		}
+
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidPassword):
+			status = http.StatusForbidden
+		case errors.Is(err, postgres.ErrUserNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, postgres.ErrStatusTransition):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.SetCookie("refresh_token", "", -1, "/", "", true, true)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":     "account scheduled for deletion",
+		"purge_after": purgeAfter,
+	})
+}
+
+func (h *AuthHandler) ExportAccount(c *gin.Context) {
+	// выгрузка проходит всю историю входов и журнал аудита пользователя
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	export, err := h.service.ExportAccount(ctx, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="ahub-export.json"`)
+	c.JSON(http.StatusOK, export)
+}
+
+func contactErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, postgres.ErrEmailTaken), errors.Is(err, postgres.ErrPhoneTaken):
+		return http.StatusConflict
+	case errors.Is(err, identifier.ErrInvalidEmail), errors.Is(err, identifier.ErrInvalidPhone),
+		errors.Is(err, ErrSameContact), errors.Is(err, ErrForeignPending), errors.Is(err, ErrIdentifierSet),
+		errors.Is(err, ErrPendingNotFound), errors.Is(err, ErrInvalidCode):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, postgres.ErrUserNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventLogin        = "login"
+	EventRefresh      = "refresh"
+	EventRegistration = "registration"
+)
+
+const (
+	defaultActivityLimit = 20
+	maxActivityLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type ActivityEvent struct {
+	ID            string    `json:"id"`
+	Event         string    `json:"event"`
+	Method        string    `json:"method,omitempty"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	Impersonator  string    `json:"impersonator,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ActivityPage struct {
+	Events     []ActivityEvent `json:"events"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// recordEvent пишет событие в ленту активности; ошибка записи не должна ломать вход.
+// method — значения amr через запятую, reason — пусто для успешных событий.
+func (s *AuthService) recordEvent(ctx context.Context, userID, event, method, reason string) {
+	info := requestctx.From(ctx)
+
+	err := s.logins.CreateLoginEvent(ctx, &postgres.LoginEvent{
+		UserID:        userID,
+		Event:         event,
+		Method:        method,
+		Success:       reason == "",
+		FailureReason: reason,
+		IP:            info.IP,
+		UserAgent:     info.UserAgent,
+		Impersonator:  info.Impersonator,
+	})
+	if err != nil {
+		s.storage.bd.Log.Error("record login event", slog.String("event", event), slog.String("error", err.Error()))
+	}
+}
+
+// ListActivity отдаёт страницу событий от новых к старым; пустой cursor — первая страница.
+func (s *AuthService) ListActivity(ctx context.Context, userID, cursor string, limit int) (*ActivityPage, error) {
+	if limit <= 0 {
+		limit = defaultActivityLimit
+	}
+	limit = min(limit, maxActivityLimit)
+
+	return s.listActivity(ctx, userID, cursor, limit)
+}
+
+func (s *AuthService) listActivity(ctx context.Context, userID, cursor string, limit int) (*ActivityPage, error) {
+	var after *postgres.LoginEventCursor
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	// одна лишняя строка показывает, есть ли следующая страница
+	events, err := s.storage.ListLoginEvents(ctx, userID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ActivityPage{Events: make([]ActivityEvent, 0, min(len(events), limit))}
+	for i, e := range events {
+		if i == limit {
+			page.NextCursor = encodeCursor(events[i-1])
+			break
+		}
+		page.Events = append(page.Events, ActivityEvent{
+			ID:            e.ID,
+			Event:         e.Event,
+			Method:        e.Method,
+			Success:       e.Success,
+			FailureReason: e.FailureReason,
+			IP:            e.IP,
+			UserAgent:     e.UserAgent,
+			Impersonator:  e.Impersonator,
+			CreatedAt:     e.CreatedAt,
+		})
+	}
+
+	return page, nil
+}
+
+func encodeCursor(e postgres.LoginEvent) string {
+	raw := strconv.FormatInt(e.CreatedAt.UnixMicro(), 10) + "|" + e.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*postgres.LoginEventCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	micros, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &postgres.LoginEventCursor{CreatedAt: time.UnixMicro(micros), ID: id}, nil
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/storage/postgres"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultUsersLimit = 50
+	maxUsersLimit     = 200
+)
+
+type AdminUser struct {
+	ID              string     `json:"id"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Phone           *string    `json:"phone"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	Status          string     `json:"status"`
+	StatusReason    *string    `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	PurgeAfter      *time.Time `json:"purge_after,omitempty"`
+}
+
+type AdminUserDetails struct {
+	AdminUser
+	Roles               []string `json:"roles"`
+	TOTPEnabled         bool     `json:"totp_enabled"`
+	WebAuthnCredentials int      `json:"webauthn_credentials"`
+	ActiveSessions      int      `json:"active_sessions"`
+	TrustedDevices      int      `json:"trusted_devices"`
+}
+
+type AdminUserFilter struct {
+	Email       string
+	Phone       string
+	Name        string
+	Status      string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Cursor      string
+	Limit       int
+}
+
+type AdminUserPage struct {
+	Users      []AdminUser `json:"users"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// ListUsers и GetUserDetails показывают персональные данные, поэтому каждый просмотр пишется в аудит.
+func (s *AuthService) ListUsers(ctx context.Context, actor string, f AdminUserFilter) (*AdminUserPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultUsersLimit
+	}
+	limit = min(limit, maxUsersLimit)
+
+	filter := postgres.UserFilter{
+		Email:  f.Email,
+		Phone:  f.Phone,
+		Name:   f.Name,
+		Status: f.Status,
+		Limit:  limit + 1,
+	}
+	if !f.CreatedFrom.IsZero() {
+		filter.CreatedFrom = &f.CreatedFrom
+	}
+	if !f.CreatedTo.IsZero() {
+		filter.CreatedTo = &f.CreatedTo
+	}
+	if f.Cursor != "" {
+		cursor, err := decodeUserCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = cursor
+	}
+
+	users, err := s.storage.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AdminUserPage{Users: make([]AdminUser, 0, min(len(users), limit))}
+	for i := range users {
+		if i == limit {
+			prev := users[i-1]
+			page.NextCursor = encodeUserCursor(prev.CreatedAt, prev.ID)
+			break
+		}
+		page.Users = append(page.Users, toAdminUser(&users[i]))
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:  actor,
+		Action: audit.ActionUsersListed,
+		Metadata: map[string]any{
+			"email":  f.Email,
+			"phone":  f.Phone,
+			"name":   f.Name,
+			"status": f.Status,
+			"cursor": f.Cursor,
+			"count":  len(page.Users),
+		},
+	})
+
+	return page, nil
+}
+
+func (s *AuthService) GetUserDetails(ctx context.Context, actor, userID string) (*AdminUserDetails, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.userAccess(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &AdminUserDetails{AdminUser: toAdminUser(user), Roles: access.Roles}
+	if details.Roles == nil {
+		details.Roles = []string{}
+	}
+
+	if row, err := s.storage.GetTOTP(ctx, userID); err == nil {
+		details.TOTPEnabled = row.EnabledAt != nil
+	} else if !errors.Is(err, postgres.ErrTOTPNotFound) {
+		return nil, err
+	}
+
+	creds, err := s.storage.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	details.WebAuthnCredentials = len(creds)
+
+	sessions, err := s.ListSessions(ctx, userID, "")
+	if err != nil {
+		return nil, err
+	}
+	details.ActiveSessions = len(sessions)
+
+	devices, err := s.storage.ListTrustedDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	details.TrustedDevices = len(devices)
+
+	s.recordAudit(ctx, audit.Event{Actor: actor, Subject: userID, Action: audit.ActionUserViewed})
+
+	return details, nil
+}
+
+// ForcePasswordReset заменяет пароль случайным, завершает сессии и просит пользователя
+// задать новый пароль через обычное восстановление.
+func (s *AuthService) ForcePasswordReset(ctx context.Context, actor, userID string) error {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.DeletedAt != nil {
+		return postgres.ErrUserNotFound
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	// хеш настоящий, поэтому проверка старого пароля просто не совпадёт и займёт обычное время
+	hash, err := s.hasher.Hash(ctx, secret)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.InTx(ctx, func(tx *AuthStorage) error {
+		if err := tx.UpdatePassword(ctx, userID, hash); err != nil {
+			return err
+		}
+		return s.revokeAllSessions(ctx, tx, userID, "password_reset")
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{Actor: actor, Subject: userID, Action: audit.ActionPasswordResetForced})
+
+	for _, to := range []*string{user.Email, user.Phone} {
+		if to != nil {
+			s.sendAsync(ctx, *to,
+				"Your password was reset",
+				"For your security, support has reset the password on your account and signed you out everywhere. Use \"Forgot password\" to set a new one.",
+			)
+		}
+	}
+
+	return nil
+}
+
+func (s *AuthService) RevokeUserSessions(ctx context.Context, actor, userID string) error {
+	if _, err := s.storage.GetUser(ctx, userID); err != nil {
+		return err
+	}
+
+	err := s.storage.InTx(ctx, func(tx *AuthStorage) error {
+		return s.revokeAllSessions(ctx, tx, userID, "admin")
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{Actor: actor, Subject: userID, Action: audit.ActionSessionsRevoked})
+
+	return nil
+}
+
+// AdminDeleteUser удаляет аккаунт так же, как DeleteAccount, но без пароля пользователя.
+func (s *AuthService) AdminDeleteUser(ctx context.Context, actor, userID string) (time.Time, error) {
+	user, err := s.accounts.GetUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return s.scheduleDeletion(ctx, actor, user)
+}
+
+func toAdminUser(u *postgres.User) AdminUser {
+	return AdminUser{
+		ID:              u.ID,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		Phone:           u.Phone,
+		PhoneVerifiedAt: u.PhoneVerifiedAt,
+		Status:          u.Status,
+		StatusReason:    u.StatusReason,
+		StatusChangedAt: u.StatusChangedAt,
+		CreatedAt:       u.CreatedAt,
+		DeletedAt:       u.DeletedAt,
+		PurgeAfter:      u.PurgeAfter,
+	}
+}
+
+func encodeUserCursor(createdAt time.Time, id string) string {
+	raw := strconv.FormatInt(createdAt.UnixMicro(), 10) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeUserCursor(cursor string) (*postgres.UserCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	micros, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &postgres.UserCursor{CreatedAt: time.UnixMicro(micros), ID: id}, nil
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UsersQuery struct {
+	Email       string    `form:"email" binding:"max=254"`
+	Phone       string    `form:"phone" binding:"max=32"`
+	Name        string    `form:"name" binding:"max=128"`
+	Status      string    `form:"status" binding:"omitempty,oneof=active disabled locked pending_deletion"`
+	CreatedFrom time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor      string    `form:"cursor" binding:"omitempty,max=256"`
+	Limit       int       `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+// StatusReasonRequest: причина видна администраторам и сохраняется в журнале аудита.
+type StatusReasonRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	var req UsersQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	page, err := h.service.ListUsers(ctx, adminActor(c), AdminUserFilter{
+		Email:       req.Email,
+		Phone:       req.Phone,
+		Name:        req.Name,
+		Status:      req.Status,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		Cursor:      req.Cursor,
+		Limit:       req.Limit,
+	})
+	if err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *AuthHandler) GetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	details, err := h.service.GetUserDetails(ctx, adminActor(c), userID)
+	if err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+func (h *AuthHandler) DisableUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req StatusReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.DisableUser(ctx, adminActor(c), userID, req.Reason); err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user disabled"})
+}
+
+func (h *AuthHandler) EnableUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.EnableUser(ctx, adminActor(c), userID); err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user enabled"})
+}
+
+func (h *AuthHandler) LockUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req StatusReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.LockUser(ctx, adminActor(c), userID, req.Reason); err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user locked"})
+}
+
+func (h *AuthHandler) RestoreUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RestoreUser(ctx, adminActor(c), userID); err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user restored"})
+}
+
+func (h *AuthHandler) ForcePasswordReset(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.service.ForcePasswordReset(ctx, adminActor(c), userID); err != nil {
+		if respondOverloaded(c, err) {
+			return
+		}
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset, user signed out"})
+}
+
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RevokeUserSessions(ctx, adminActor(c), userID); err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "sessions revoked"})
+}
+
+func (h *AuthHandler) DeleteUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	purgeAfter, err := h.service.AdminDeleteUser(ctx, adminActor(c), userID)
+	if err != nil {
+		respondAdminUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "account scheduled for deletion",
+		"purge_after": purgeAfter.UTC(),
+	})
+}
+
+// userIDParam: идентификаторы пользователей — UUID, остальное заведомо не найдётся.
+func userIDParam(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": postgres.ErrUserNotFound.Error()})
+		return "", false
+	}
+	return id, true
+}
+
+func respondAdminUserError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidCursor):
+		status = http.StatusBadRequest
+	case errors.Is(err, postgres.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, postgres.ErrStatusTransition):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -1,11 +1,18 @@
 package auth
 
 import (
+	"ahub/internal/identifier"
+	"ahub/internal/password"
+	"ahub/storage/postgres"
 	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 type AuthHandler struct {
@@ -17,10 +24,10 @@ func NewHandler(service *AuthService) *AuthHandler {
 }
 
 type RegisterRequest struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Login     string `json:"login"`
-	Password  string `json:"password"`
+	FirstName string `json:"first_name" binding:"required,max=64,name"`
+	LastName  string `json:"last_name" binding:"required,max=64,name"`
+	Login     string `json:"login" binding:"required,max=254,login"`
+	Password  string `json:"password" binding:"required,max=1024"`
 }
 
 type RegisterResponse struct {
@@ -28,8 +35,8 @@ type RegisterResponse struct {
 }
 
 type ConfirmRegisterRequest struct {
-	Token string `json:"token"`
-	Code  string `json:"code"`
+	Token string `json:"token" binding:"required,uuid"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
 }
 
 type ConfirmRegisterResponse struct {
@@ -37,14 +44,14 @@ type ConfirmRegisterResponse struct {
 }
 
 type LoginRequest struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login    string `json:"login" binding:"required,max=254,login"`
+	Password string `json:"password" binding:"required,max=1024"`
 }
 
 func (h *AuthHandler) StartRegistration(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -53,7 +60,27 @@ func (h *AuthHandler) StartRegistration(c *gin.Context) {
 
 	token, err := h.service.StartRegistration(ctx, req.FirstName, req.LastName, req.Login, req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			respondBindError(c, err)
+			return
+		}
+
+		if respondOverloaded(c, err) {
+			return
+		}
+
+		var perr *password.PolicyError
+		if errors.As(err, &perr) {
+			respondPolicyError(c, "password", perr)
+			return
+		}
+
+		status := http.StatusInternalServerError
+		if errors.Is(err, identifier.ErrInvalidEmail) || errors.Is(err, identifier.ErrInvalidPhone) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -63,7 +90,7 @@ func (h *AuthHandler) StartRegistration(c *gin.Context) {
 func (h *AuthHandler) CreateNewUser(c *gin.Context) {
 	var req ConfirmRegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -94,22 +121,57 @@ func (h *AuthHandler) CreateNewUser(c *gin.Context) {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	ctx, cancel := loginContext(c)
 	defer cancel()
 
-	accessToken, refreshToken, err := h.service.Login(ctx, req.Login, req.Password)
+	result, err := h.service.Login(ctx, req.Login, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			respondBindError(c, err)
+			return
+		}
+
+		if respondOverloaded(c, err) {
+			return
+		}
+
+		if respondThrottled(c, err) {
+			return
+		}
+
+		status := http.StatusUnauthorized
+		switch {
+		case errors.Is(err, ErrAccountDisabled):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrAccountLocked):
+			status = http.StatusLocked
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondLogin(c, result)
+}
+
+// respondLogin отдаёт токены либо, если нужен второй фактор, mfa_token для /auth/2fa/verify.
+func respondLogin(c *gin.Context, result *LoginResult) {
+	if result.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    result.MFAToken,
+			"mfa_methods":  result.MFAMethods,
+		})
 		return
 	}
 
 	c.SetCookie(
 		"refresh_token",
-		refreshToken,
+		result.RefreshToken,
 		60*60*24*30, // 30 дней
 		"/",
 		"",
@@ -117,11 +179,30 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		true,
 	)
 
+	if result.DeviceToken != "" {
+		c.SetCookie(
+			trustedDeviceCookie,
+			result.DeviceToken,
+			int(time.Until(result.DeviceExpiresAt).Seconds()),
+			"/",
+			"",
+			true,
+			true,
+		)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"access_token": accessToken,
+		"access_token": result.AccessToken,
 	})
 }
 
+// loginContext — контекст для шагов входа: с таймаутом и cookie доверенного устройства.
+func loginContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	deviceToken, _ := c.Cookie(trustedDeviceCookie)
+	ctx := withDeviceToken(c.Request.Context(), deviceToken)
+	return context.WithTimeout(ctx, 5*time.Second)
+}
+
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	refreshToken, err := c.Cookie("refresh_token")
 	if err != nil {
@@ -134,7 +215,12 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 
 	accessToken, newRefreshToken, err := h.service.Refresh(ctx, refreshToken)
 	if err != nil {
-		c.JSON(401, gin.H{"error": err.Error()})
+		status := 401
+		if errors.Is(err, ErrAccountDisabled) || errors.Is(err, ErrAccountLocked) {
+			status = 403
+			c.SetCookie("refresh_token", "", -1, "/", "", true, true)
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -159,11 +245,107 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	if refreshToken != "" {
-		_ = h.service.storage.DeleteRefreshToken(ctx, refreshToken)
-	}
+	_ = h.service.Logout(ctx, c.GetString("user_id"), refreshToken)
 
 	c.SetCookie("refresh_token", "", -1, "/", "", true, true)
 
 	c.JSON(200, gin.H{"message": "logged out"})
 }
+
+// ReauthenticateRequest: нужен либо пароль, либо TOTP/резервный код.
+type ReauthenticateRequest struct {
+	Password string `json:"password" binding:"required_without=Code,max=1024"`
+	Code     string `json:"code" binding:"required_without=Password,max=32"`
+}
+
+func (h *AuthHandler) Reauthenticate(c *gin.Context) {
+	var req ReauthenticateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	accessToken, err := h.service.Reauthenticate(ctx, c.GetString("user_id"), req.Password, req.Code)
+	if err != nil {
+		if respondOverloaded(c, err) {
+			return
+		}
+
+		if respondThrottled(c, err) {
+			return
+		}
+
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidPassword), errors.Is(err, ErrInvalidMFACode):
+			status = http.StatusUnauthorized
+		case errors.Is(err, ErrTOTPNotEnabled), errors.Is(err, ErrReauthMethod):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+	})
+}
+
+// respondOverloaded отвечает 503, если пароль не успели проверить: пул хеширования
+// переполнен или истёк дедлайн запроса.
+func respondOverloaded(c *gin.Context, err error) bool {
+	var busy *password.BusyError
+	switch {
+	case errors.As(err, &busy):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(busy.RetryAfter.Seconds()))))
+	case errors.Is(err, context.DeadlineExceeded):
+		c.Header("Retry-After", "1")
+	default:
+		return false
+	}
+
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is busy, retry later"})
+	return true
+}
+
+// respondThrottled отвечает 429 или 423, если вход задержан или заблокирован после неудачных попыток,
+// и 503, если счётчик попыток недоступен: сбой Redis не должен выглядеть как неверный пароль.
+func respondThrottled(c *gin.Context, err error) bool {
+	if errors.Is(err, ErrLockoutUnavailable) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is busy, retry later"})
+		return true
+	}
+
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	status := http.StatusTooManyRequests
+	if throttled.Locked {
+		status = http.StatusLocked
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	c.JSON(status, gin.H{"error": err.Error()})
+	return true
+}
+
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.UnlockUser(ctx, adminActor(c), c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// Impersonate отдаёт токен только в теле ответа: refresh-cookie не ставится, по истечении
+// токена нужно запросить новый.
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.service.Impersonate(ctx, adminActor(c), userID, req.Reason)
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": result.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(time.Until(result.ExpiresAt).Seconds()),
+		"impersonated": true,
+	})
+}
+
+func respondImpersonationError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrImpersonateSelf):
+		status = http.StatusBadRequest
+	case errors.Is(err, postgres.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAccountDisabled), errors.Is(err, ErrAccountLocked):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -8,29 +8,106 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Значения claim amr (RFC 8176); "email" — нестандартное, для кода или ссылки из письма.
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	AMRSMS         = "sms"
+	AMREmail       = "email"
+	AMRMFA         = "mfa"
+)
+
+// AuthContext — когда и какими способами пользователь подтвердил личность (claims auth_time и amr).
+type AuthContext struct {
+	Time    time.Time
+	Methods []string
+}
+
+// Access — роли и права пользователя на момент выдачи токена (claims roles и permissions).
+// Изменения ролей вступают в силу со следующим обновлением токена.
+type Access struct {
+	Roles       []string
+	Permissions []string
+}
+
+// AccessClaims: Impersonator — sub из claim act (RFC 8693), если токен выдан администратору,
+// действующему от имени пользователя UserID; для обычных токенов пусто.
+type AccessClaims struct {
+	UserID       string
+	Impersonator string
+	AuthContext
+	Access
+}
+
 type JWTManager struct {
-	secretKey string
-	ttl       time.Duration
+	secretKey        string
+	ttl              time.Duration
+	stepUpTTL        time.Duration
+	impersonationTTL time.Duration
 }
 
-func NewJWTManager(secret string, ttl time.Duration) *JWTManager {
+func NewJWTManager(secret string, ttl, stepUpTTL, impersonationTTL time.Duration) *JWTManager {
 	if secret == "" {
 		log.Fatal("JWT secret is empty! Set JWT_SECRET in env or config")
 	}
 
 	return &JWTManager{
-		secretKey: secret,
-		ttl:       ttl,
+		secretKey:        secret,
+		ttl:              ttl,
+		stepUpTTL:        stepUpTTL,
+		impersonationTTL: impersonationTTL,
 	}
 }
 
-func (j *JWTManager) GenerateAccessToken(userID string) (string, error) {
+func (j *JWTManager) GenerateAccessToken(userID string, auth AuthContext, access Access) (string, error) {
+	return j.generate(userID, auth, access, j.ttl)
+}
+
+// GenerateElevatedToken выдаёт короткоживущий токен после повторной аутентификации
+// для операций под RequireRecentAuth.
+func (j *JWTManager) GenerateElevatedToken(userID string, auth AuthContext, access Access) (string, error) {
+	return j.generate(userID, auth, access, j.stepUpTTL)
+}
+
+// GenerateImpersonationToken выдаёт токен пользователя userID для администратора actor.
+// В токене нет auth_time и amr, поэтому RequireRecentAuth его не пропускает, а impersonated
+// позволяет клиентам и сервисам отличить такой токен, не разбирая act.
+func (j *JWTManager) GenerateImpersonationToken(userID, actor string, access Access) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(j.impersonationTTL)
+
 	claims := jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(j.ttl).Unix(),
-		"iat": time.Now().Unix(),
+		"sub":          userID,
+		"exp":          expiresAt.Unix(),
+		"iat":          now.Unix(),
+		"act":          map[string]any{"sub": actor},
+		"impersonated": true,
+		"roles":        access.Roles,
+		"permissions":  access.Permissions,
 	}
 
+	token, err := j.sign(claims)
+	return token, expiresAt, err
+}
+
+func (j *JWTManager) generate(userID string, auth AuthContext, access Access, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"sub":         userID,
+		"exp":         now.Add(ttl).Unix(),
+		"iat":         now.Unix(),
+		"auth_time":   auth.Time.Unix(),
+		"amr":         auth.Methods,
+		"roles":       access.Roles,
+		"permissions": access.Permissions,
+	}
+
+	return j.sign(claims)
+}
+
+func (j *JWTManager) sign(claims jwt.MapClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(j.secretKey))
 }
@@ -47,7 +124,7 @@ func (j *JWTManager) Validate(tokenStr string) (string, error) {
 	return claims["sub"].(string), nil
 }
 
-func (j *JWTManager) ParseAccessToken(tokenStr string) (string, error) {
+func (j *JWTManager) ParseAccessToken(tokenStr string) (*AccessClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -55,18 +132,50 @@ func (j *JWTManager) ParseAccessToken(tokenStr string) (string, error) {
 		return []byte(j.secretKey), nil
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return "", errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
 	}
 
 	sub, ok := claims["sub"].(string)
 	if !ok {
-		return "", errors.New("invalid sub claim")
+		return nil, errors.New("invalid sub claim")
+	}
+
+	result := &AccessClaims{UserID: sub}
+
+	// токены, выпущенные до появления auth_time, считаются давними: step-up для них обязателен
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		result.Time = time.Unix(int64(authTime), 0)
+	}
+
+	result.Methods = stringsClaim(claims, "amr")
+	result.Roles = stringsClaim(claims, "roles")
+	result.Permissions = stringsClaim(claims, "permissions")
+
+	if act, ok := claims["act"].(map[string]any); ok {
+		actor, _ := act["sub"].(string)
+		if actor == "" {
+			return nil, errors.New("invalid act claim")
+		}
+		result.Impersonator = actor
+	}
+
+	return result, nil
+}
+
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	values, _ := claims[name].([]any)
+
+	var result []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
 	}
 
-	return sub, nil
+	return result
 }
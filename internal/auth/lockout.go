@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"ahub/internal/config"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLockoutUnavailable — счётчик неудачных попыток недоступен (Redis не отвечает);
+// без него пароль не проверяется, клиенту отдаётся 503 без подробностей.
+var ErrLockoutUnavailable = errors.New("login attempt tracking is unavailable")
+
+// ThrottledError — вход временно запрещён: либо действует прогрессивная задержка,
+// либо аккаунт/IP заблокирован после серии неудач.
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return "account temporarily locked due to too many failed login attempts"
+	}
+	return "too many failed login attempts, retry later"
+}
+
+// Lockout считает неудачные входы в Redis по пользователю и по IP.
+// После FreeAttempts неудач каждая следующая попытка откладывается на BaseDelay*2^n
+// (не больше MaxDelay), после Threshold неудач пользователь блокируется на Duration.
+// Блокировка снимается сама по истечении TTL ключа или через Unlock.
+type Lockout struct {
+	rdb          *redis.Client
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	threshold    int
+	ipThreshold  int
+	window       time.Duration
+	duration     time.Duration
+}
+
+func NewLockout(rdb *redis.Client, cfg config.LockoutConfig) *Lockout {
+	return &Lockout{
+		rdb:          rdb,
+		freeAttempts: cfg.FreeAttempts,
+		baseDelay:    cfg.BaseDelayDuration(),
+		maxDelay:     cfg.MaxDelayDuration(),
+		threshold:    cfg.Threshold,
+		ipThreshold:  cfg.IPThreshold,
+		window:       cfg.WindowDuration(),
+		duration:     cfg.LockDuration(),
+	}
+}
+
+// UserKey — ключ пользователя; для неизвестного логина используется хеш логина,
+// чтобы несуществующие аккаунты «блокировались» так же, как настоящие.
+func UserKey(userID, login string) string {
+	if userID != "" {
+		return "user:" + userID
+	}
+	sum := sha256.Sum256([]byte(login))
+	return "login:" + hex.EncodeToString(sum[:])
+}
+
+func (l *Lockout) Check(ctx context.Context, userKey, ip string) error {
+	keys := []string{lockKey(userKey)}
+	if ip != "" {
+		keys = append(keys, lockKey(ipKey(ip)))
+	}
+
+	for _, key := range keys {
+		if ttl, ok, err := l.ttl(ctx, key); err != nil {
+			return fmt.Errorf("%w: %w", ErrLockoutUnavailable, err)
+		} else if ok {
+			return &ThrottledError{RetryAfter: ttl, Locked: true}
+		}
+	}
+
+	if ttl, ok, err := l.ttl(ctx, delayKey(userKey)); err != nil {
+		return fmt.Errorf("%w: %w", ErrLockoutUnavailable, err)
+	} else if ok {
+		return &ThrottledError{RetryAfter: ttl}
+	}
+
+	return nil
+}
+
+// Fail регистрирует неудачу и возвращает true, если именно она привела к блокировке пользователя.
+func (l *Lockout) Fail(ctx context.Context, userKey, ip string) (bool, error) {
+	userFailures, err := l.incr(ctx, failuresKey(userKey))
+	if err != nil {
+		return false, err
+	}
+
+	if ip != "" {
+		ipFailures, err := l.incr(ctx, failuresKey(ipKey(ip)))
+		if err != nil {
+			return false, err
+		}
+
+		if l.ipThreshold > 0 && ipFailures >= int64(l.ipThreshold) {
+			if err := l.lock(ctx, ipKey(ip)); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if l.threshold > 0 && userFailures >= int64(l.threshold) {
+		locked, err := l.rdb.SetNX(ctx, lockKey(userKey), 1, l.duration).Result()
+		if err != nil {
+			return false, err
+		}
+		if err := l.rdb.Del(ctx, failuresKey(userKey), delayKey(userKey)).Err(); err != nil {
+			return false, err
+		}
+		return locked, nil
+	}
+
+	if over := userFailures - int64(l.freeAttempts); over > 0 {
+		if err := l.rdb.Set(ctx, delayKey(userKey), 1, l.delay(over)).Err(); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// Reset вызывается после успешного входа.
+func (l *Lockout) Reset(ctx context.Context, userKey string) error {
+	return l.rdb.Del(ctx, failuresKey(userKey), delayKey(userKey)).Err()
+}
+
+// Unlock снимает блокировку пользователя досрочно (административное действие).
+func (l *Lockout) Unlock(ctx context.Context, userID string) error {
+	key := UserKey(userID, "")
+	return l.rdb.Del(ctx, lockKey(key), failuresKey(key), delayKey(key)).Err()
+}
+
+func (l *Lockout) delay(over int64) time.Duration {
+	d := l.baseDelay
+	for i := int64(1); i < over && d < l.maxDelay; i++ {
+		d *= 2
+	}
+	if d > l.maxDelay {
+		d = l.maxDelay
+	}
+	return d
+}
+
+func (l *Lockout) lock(ctx context.Context, key string) error {
+	pipe := l.rdb.TxPipeline()
+	pipe.Set(ctx, lockKey(key), 1, l.duration)
+	pipe.Del(ctx, failuresKey(key))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (l *Lockout) incr(ctx context.Context, key string) (int64, error) {
+	pipe := l.rdb.TxPipeline()
+	n := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, l.window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("count login failure: %w", err)
+	}
+	return n.Val(), nil
+}
+
+func (l *Lockout) ttl(ctx context.Context, key string) (time.Duration, bool, error) {
+	ttl, err := l.rdb.PTTL(ctx, key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, false, err
+	}
+	// -2: ключа нет, -1: ключ без срока (не бывает, но считаем отсутствием)
+	if ttl <= 0 {
+		return 0, false, nil
+	}
+	return ttl, true, nil
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func failuresKey(key string) string {
+	return "login_failures:" + key
+}
+
+func delayKey(key string) string {
+	return "login_delay:" + key
+}
+
+func lockKey(key string) string {
+	return "login_lock:" + key
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"ahub/internal/config"
+	"ahub/internal/identifier"
+	"ahub/internal/password"
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Недоступный Redis не должен превращаться в «неверный пароль» и не должен попадать в ответ.
+func TestLoginWithLockoutUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	rdb := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	mr.Close()
+
+	s := &AuthService{
+		logins:   fakeLogins{},
+		auditLog: fakeAudit{},
+		ids:      identifier.NewNormalizer("RU"),
+		hasher: password.NewPool(fakeHasher{}, config.HashPoolConfig{
+			Concurrency: 1,
+			QueueDepth:  1,
+			RetryAfter:  "1s",
+		}),
+		lockout: newTestLockout(rdb, 100),
+	}
+
+	for _, login := range []string{"user@known.example", "user@unknown.example"} {
+		_, err := s.Login(context.Background(), login, "wrong-password-123")
+		if !errors.Is(err, ErrLockoutUnavailable) {
+			t.Fatalf("login %s: got %v, want ErrLockoutUnavailable", login, err)
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		if !respondThrottled(c, err) {
+			t.Fatal("lockout outage is not handled by respondThrottled")
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+		}
+		if body := w.Body.String(); strings.Contains(body, addr) {
+			t.Fatalf("response leaks redis address: %s", body)
+		}
+	}
+}
+
+func TestLockoutThrottlesAfterThreshold(t *testing.T) {
+	l := newTestLockout(newTestRedis(t), 3)
+	ctx := context.Background()
+	key := UserKey("u1", "")
+
+	for range 3 {
+		if err := l.Check(ctx, key, ""); err != nil {
+			t.Fatalf("check before lock: %v", err)
+		}
+		if _, err := l.Fail(ctx, key, ""); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+	}
+
+	var throttled *ThrottledError
+	if err := l.Check(ctx, key, ""); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("check after threshold: got %v, want locked ThrottledError", err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > 15*time.Minute {
+		t.Fatalf("retry after = %v", throttled.RetryAfter)
+	}
+}
+
+// Повторная проверка пароля (смена пароля, удаление аккаунта) идёт через тот же счётчик, что и вход.
+func TestConfirmPasswordIsThrottled(t *testing.T) {
+	s := &AuthService{
+		hasher: password.NewPool(fakeHasher{}, config.HashPoolConfig{
+			Concurrency: 1,
+			QueueDepth:  1,
+			RetryAfter:  "1s",
+		}),
+		lockout: newTestLockout(newTestRedis(t), 3),
+	}
+	user := &postgres.User{ID: "u1", PasswordHash: "fake$secret"}
+	ctx := context.Background()
+
+	for i := range 3 {
+		if err := s.confirmPassword(ctx, user, "guess"); !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidPassword", i+1, err)
+		}
+	}
+
+	var throttled *ThrottledError
+	if err := s.confirmPassword(ctx, user, "guess"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("attempt after threshold: got %v, want locked ThrottledError", err)
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/device"
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const pendingSessionRevoke = "session_revoke"
+
+var ErrRevokeLinkInvalid = errors.New("link is invalid or has already been used")
+
+type SessionRevokeData struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+// finishLogin выдаёт токены после всех проверок, записывает вход в историю и,
+// если устройство раньше не встречалось, предупреждает пользователя.
+func (s *AuthService) finishLogin(ctx context.Context, userID string, auth AuthContext) (*LoginResult, error) {
+	if err := s.checkActive(ctx, userID, EventLogin, strings.Join(auth.Methods, ",")); err != nil {
+		return nil, err
+	}
+
+	sessionID, accessToken, refreshToken, err := s.issueSession(ctx, userID, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordLogin(ctx, userID, sessionID, strings.Join(auth.Methods, ","))
+	s.recordAudit(ctx, audit.Event{
+		Actor:    userID,
+		Subject:  userID,
+		Action:   audit.ActionLogin,
+		Metadata: map[string]any{"session_id": sessionID, "amr": auth.Methods},
+	})
+
+	return &LoginResult{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// recordLogin пишет успешный вход в login_events вместе с ключом устройства. Не влияет на
+// результат входа: ошибки только пишутся в лог.
+func (s *AuthService) recordLogin(ctx context.Context, userID, sessionID, method string) {
+	info := requestctx.From(ctx)
+	dev := device.Parse(info.UserAgent, info.IP)
+
+	// до записи самого входа, иначе устройство всегда окажется знакомым
+	known, first, seenErr := s.storage.LoginDeviceSeen(ctx, userID, dev.Key())
+
+	err := s.logins.CreateLoginEvent(ctx, &postgres.LoginEvent{
+		UserID:       userID,
+		Event:        EventLogin,
+		Method:       method,
+		Success:      true,
+		IP:           info.IP,
+		UserAgent:    info.UserAgent,
+		DeviceKey:    dev.Key(),
+		Impersonator: info.Impersonator,
+	})
+	if err != nil {
+		s.storage.bd.Log.Error("record login", slog.String("error", err.Error()))
+	}
+	if seenErr != nil {
+		s.storage.bd.Log.Error("check login device", slog.String("error", seenErr.Error()))
+		return
+	}
+
+	// первый вход после регистрации — не повод для тревоги
+	if known || first || !s.alerts.Enabled {
+		return
+	}
+
+	if err := s.notifyNewDevice(ctx, userID, sessionID, dev, info.IP); err != nil {
+		s.storage.bd.Log.Error("notify new device", slog.String("error", err.Error()))
+	}
+}
+
+func (s *AuthService) notifyNewDevice(ctx context.Context, userID, sessionID string, dev device.Info, ip string) error {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	token, err := s.storage.SavePending(ctx, pendingSessionRevoke, SessionRevokeData{
+		UserID:    userID,
+		SessionID: sessionID,
+	}, s.alerts.LinkTTLDuration())
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.alerts.BaseURL, "/") + "/auth/not-me/" + token
+	body := "New sign-in to your account: " + dev.String() + ", IP " + ip +
+		", " + time.Now().UTC().Format("2006-01-02 15:04 UTC") + ".\n" +
+		"If this wasn't you, follow this link to end that session, then change your password: " + link
+
+	for _, to := range []*string{user.Email, user.Phone} {
+		if to != nil {
+			s.sendAsync(ctx, *to, "New sign-in to your account", body)
+		}
+	}
+
+	return nil
+}
+
+// CheckRevokeLink проверяет ссылку «это был не я», не расходуя её.
+func (s *AuthService) CheckRevokeLink(ctx context.Context, token string) error {
+	var data SessionRevokeData
+	if err := s.storage.GetPending(ctx, pendingSessionRevoke, token, &data); err != nil {
+		if errors.Is(err, ErrPendingNotFound) {
+			return ErrRevokeLinkInvalid
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RevokeFromAlert завершает сессию по ссылке «это был не я». Ссылка одноразовая.
+func (s *AuthService) RevokeFromAlert(ctx context.Context, token string) error {
+	var data SessionRevokeData
+	if err := s.storage.TakePending(ctx, pendingSessionRevoke, token, &data); err != nil {
+		if errors.Is(err, ErrPendingNotFound) {
+			return ErrRevokeLinkInvalid
+		}
+		return err
+	}
+
+	err := s.revokeSession(ctx, data.UserID, data.SessionID, "login_alert")
+	if err != nil && !errors.Is(err, postgres.ErrSessionNotFound) {
+		return err
+	}
+
+	// cookie доверенных устройств могли утечь вместе с сессией
+	return s.storage.DeleteUserTrustedDevices(ctx, data.UserID)
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/config"
+	"ahub/internal/identifier"
+	"ahub/internal/password"
+	"ahub/storage"
+	"ahub/storage/postgres"
+	redisstorage "ahub/storage/redis"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeHasher тратит на проверку одно и то же время и никогда не подтверждает пароль.
+type fakeHasher struct {
+	cost time.Duration
+}
+
+func (h fakeHasher) Hash(password string) (string, error) {
+	return "fake$" + password, nil
+}
+
+func (h fakeHasher) Verify(password, encoded string) (bool, bool, error) {
+	time.Sleep(h.cost)
+	return false, false, nil
+}
+
+// fakeLogins знает всех пользователей с логином на known.example.
+type fakeLogins struct{}
+
+func (fakeLogins) GetUserByLogin(ctx context.Context, login string) (*postgres.UserInfo, error) {
+	if !strings.HasSuffix(login, "@known.example") {
+		return nil, errors.New("user not found")
+	}
+	return &postgres.UserInfo{ID: login, PasswordHash: "fake$secret"}, nil
+}
+
+func (fakeLogins) CreateLoginEvent(ctx context.Context, event *postgres.LoginEvent) error {
+	return nil
+}
+
+type fakeAudit struct{}
+
+func (fakeAudit) Record(ctx context.Context, e audit.Event) error { return nil }
+
+func (fakeAudit) RecordAsync(ctx context.Context, e audit.Event) error { return nil }
+
+func (fakeAudit) Query(ctx context.Context, f audit.Filter) (*audit.Page, error) {
+	return &audit.Page{}, nil
+}
+
+func newTimingService(t *testing.T) *AuthService {
+	t.Helper()
+
+	rdb := newTestRedis(t)
+
+	return &AuthService{
+		logins:   fakeLogins{},
+		auditLog: fakeAudit{},
+		ids:      identifier.NewNormalizer("RU"),
+		hasher: password.NewPool(fakeHasher{cost: 3 * time.Millisecond}, config.HashPoolConfig{
+			Concurrency: 1,
+			QueueDepth:  1,
+			RetryAfter:  "1s",
+		}),
+		lockout: newTestLockout(rdb, 100),
+	}
+}
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	return rdb
+}
+
+// newTestLockout блокирует после threshold неудач подряд, без промежуточных задержек.
+func newTestLockout(rdb *redis.Client, threshold int) *Lockout {
+	return NewLockout(rdb, config.LockoutConfig{
+		FreeAttempts: threshold,
+		BaseDelay:    "1s",
+		MaxDelay:     "1s",
+		Threshold:    threshold,
+		Window:       "15m",
+		Duration:     "15m",
+	})
+}
+
+// По времени ответа нельзя отличить существующий логин от несуществующего: медианы
+// неудачных входов в обоих случаях определяются проверкой хеша.
+func TestLoginTimingDoesNotRevealAccounts(t *testing.T) {
+	s := newTimingService(t)
+	ctx := context.Background()
+
+	// у каждой попытки свой логин, чтобы не сработала задержка блокировки
+	assertSameTiming(t, "login", func(login string) {
+		if _, err := s.Login(ctx, login, "wrong-password-123"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("login %s: got %v, want ErrInvalidCredentials", login, err)
+		}
+	})
+}
+
+// Регистрация, сброс пароля и ссылка для входа выполняют одну и ту же работу для занятого
+// и свободного адреса; хеш здесь бесплатный, чтобы пропущенное сохранение было заметно.
+func TestStartFlowsTimingDoesNotRevealAccounts(t *testing.T) {
+	s := newTimingService(t)
+	s.hasher = password.NewPool(fakeHasher{}, config.HashPoolConfig{Concurrency: 1, QueueDepth: 1, RetryAfter: "1s"})
+	s.policy = password.NewPolicy(config.PasswordConfig{MinLength: 10, MaxLength: 128}, nil)
+	s.storage = &AuthStorage{bd: &storage.Storage{Redis: &redisstorage.Storage{Client: newTestRedis(t)}}}
+	s.sender = nopSender{}
+	s.otpTTL = time.Minute
+	s.magic = config.MagicLinkConfig{Enabled: true, BaseURL: "http://localhost", Secret: "test", TTL: "15m", ReturnURLs: []string{"http://localhost/"}}
+	ctx := context.Background()
+
+	assertSameTiming(t, "registration", func(login string) {
+		if _, err := s.StartRegistration(ctx, "Ivan", "Petrov", login, "long-enough-passphrase-1"); err != nil {
+			t.Fatalf("start registration %s: %v", login, err)
+		}
+	})
+
+	assertSameTiming(t, "password reset", func(login string) {
+		if _, err := s.StartPasswordReset(ctx, login); err != nil {
+			t.Fatalf("start password reset %s: %v", login, err)
+		}
+	})
+
+	assertSameTiming(t, "magic link", func(login string) {
+		if _, err := s.StartMagicLink(ctx, login, ""); err != nil {
+			t.Fatalf("start magic link %s: %v", login, err)
+		}
+	})
+}
+
+// assertSameTiming сравнивает медианы call для существующих и несуществующих логинов.
+func assertSameTiming(t *testing.T, name string, call func(login string)) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("timing test")
+	}
+
+	const samples = 200
+	known := make([]time.Duration, 0, samples)
+	unknown := make([]time.Duration, 0, samples)
+
+	measure := func(login string) time.Duration {
+		start := time.Now()
+		call(login)
+		return time.Since(start)
+	}
+
+	// попеременно, чтобы фоновые колебания нагрузки влияли на обе выборки одинаково
+	for i := range samples {
+		known = append(known, measure(fmt.Sprintf("user%d@known.example", i)))
+		unknown = append(unknown, measure(fmt.Sprintf("user%d@unknown.example", i)))
+	}
+
+	knownMedian, unknownMedian := median(known), median(unknown)
+	diff := knownMedian - unknownMedian
+	if diff < 0 {
+		diff = -diff
+	}
+
+	if diff > knownMedian/5 {
+		t.Fatalf("%s timing differs: known median %v, unknown median %v", name, knownMedian, unknownMedian)
+	}
+}
+
+func median(d []time.Duration) time.Duration {
+	sorted := slices.Clone(d)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"ahub/internal/config"
+	"ahub/internal/identifier"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const pendingMagicLink = "magic_link"
+
+var (
+	ErrMagicLinkDisabled = errors.New("login by link is disabled")
+	ErrMagicLinkInvalid  = errors.New("sign-in link is invalid or expired")
+	ErrReturnURL         = errors.New("return url is not allowed")
+	ErrEmailRequired     = errors.New("sign-in links can only be sent to an email address")
+)
+
+// MagicLinkData: Binding — хеш случайного значения из cookie браузера, запросившего ссылку;
+// переход по пересланной ссылке из другого браузера не сработает.
+type MagicLinkData struct {
+	UserID    string `json:"user_id"`
+	ReturnURL string `json:"return_url"`
+	Binding   string `json:"binding"`
+}
+
+// StartMagicLink отправляет ссылку для входа и возвращает значение для cookie привязки к браузеру.
+// Для неизвестного адреса выполняется та же работа и ответ такой же, только письмо не отправляется.
+func (s *AuthService) StartMagicLink(ctx context.Context, login, returnURL string) (string, error) {
+	if !s.magic.Enabled {
+		return "", ErrMagicLinkDisabled
+	}
+
+	returnURL, err := s.MagicLinkReturnURL(returnURL)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := s.ids.Normalize(login)
+	if err != nil {
+		return "", err
+	}
+	if id.Kind != identifier.Email {
+		return "", ErrEmailRequired
+	}
+
+	binding, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	data := MagicLinkData{ReturnURL: returnURL, Binding: hashBinding(binding)}
+	if user, err := s.logins.GetUserByLogin(ctx, id.Value); err == nil {
+		data.UserID = user.ID
+	}
+
+	// запись сохраняется и для неизвестного адреса, иначе по времени ответа видно, зарегистрирован ли он
+	token, err := s.storage.SavePending(ctx, pendingMagicLink, data, s.magic.TTLDuration())
+	if err != nil {
+		return "", err
+	}
+
+	link := strings.TrimRight(s.magic.BaseURL, "/") + "/auth/magic/" + s.signMagicToken(token)
+	if data.UserID != "" {
+		s.sendAsync(ctx, id.Value, "Sign-in link",
+			"Follow this link to sign in: "+link+"\nThe link works once, only in the browser where you requested it.")
+	}
+
+	return binding, nil
+}
+
+// RedeemMagicLink гасит ссылку и возвращает результат входа и адрес, на который нужно вернуть пользователя.
+func (s *AuthService) RedeemMagicLink(ctx context.Context, signed, binding string) (*LoginResult, string, error) {
+	if !s.magic.Enabled {
+		return nil, "", ErrMagicLinkDisabled
+	}
+
+	token, ok := s.verifyMagicToken(signed)
+	if !ok {
+		return nil, "", ErrMagicLinkInvalid
+	}
+
+	var data MagicLinkData
+	if err := s.storage.TakePending(ctx, pendingMagicLink, token, &data); err != nil {
+		if errors.Is(err, ErrPendingNotFound) {
+			return nil, "", ErrMagicLinkInvalid
+		}
+		return nil, "", err
+	}
+
+	if binding == "" || subtle.ConstantTimeCompare([]byte(hashBinding(binding)), []byte(data.Binding)) != 1 {
+		return nil, data.ReturnURL, ErrMagicLinkInvalid
+	}
+
+	// ссылка для неизвестного адреса никуда не отправлялась и не гасится
+	if data.UserID == "" {
+		return nil, data.ReturnURL, ErrMagicLinkInvalid
+	}
+
+	result, err := s.completeLogin(ctx, data.UserID, AMREmail)
+	if err != nil {
+		return nil, data.ReturnURL, err
+	}
+
+	return result, data.ReturnURL, nil
+}
+
+// MagicLinkReturnURL проверяет адрес возврата по списку разрешённых (совпадение схемы и хоста);
+// пустой адрес заменяется адресом по умолчанию.
+func (s *AuthService) MagicLinkReturnURL(raw string) (string, error) {
+	if len(s.magic.ReturnURLs) == 0 {
+		return "", ErrReturnURL
+	}
+	if raw == "" {
+		return s.magic.ReturnURLs[0], nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", ErrReturnURL
+	}
+
+	for _, allowed := range s.magic.ReturnURLs {
+		a, err := url.Parse(allowed)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(a.Scheme, u.Scheme) && strings.EqualFold(a.Host, u.Host) &&
+			strings.HasPrefix(u.Path, a.Path) {
+			return u.String(), nil
+		}
+	}
+
+	return "", ErrReturnURL
+}
+
+func (s *AuthService) signMagicToken(token string) string {
+	return token + "." + magicSignature(s.magic, token)
+}
+
+func (s *AuthService) verifyMagicToken(signed string) (string, bool) {
+	token, sig, ok := strings.Cut(signed, ".")
+	if !ok {
+		return "", false
+	}
+
+	expected := magicSignature(s.magic, token)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", false
+	}
+
+	return token, true
+}
+
+func magicSignature(cfg config.MagicLinkConfig, token string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.Secret))
+	mac.Write([]byte(pendingMagicLink + ":" + token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashBinding(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"ahub/internal/identifier"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const magicBindingCookie = "magic_link_binding"
+
+type StartMagicLinkRequest struct {
+	Login     string `json:"login" binding:"required,max=254,login"`
+	ReturnURL string `json:"return_url" binding:"omitempty,max=2048,url"`
+}
+
+func (h *AuthHandler) StartMagicLink(c *gin.Context) {
+	var req StartMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	binding, err := h.service.StartMagicLink(ctx, req.Login, req.ReturnURL)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrMagicLinkDisabled):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrReturnURL), errors.Is(err, ErrEmailRequired),
+			errors.Is(err, identifier.ErrInvalidEmail), errors.Is(err, identifier.ErrInvalidPhone):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Lax: cookie уходит при переходе по ссылке из письма, но не в межсайтовых запросах
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		magicBindingCookie,
+		binding,
+		int(h.service.magic.TTLDuration().Seconds()),
+		"/auth/magic",
+		"",
+		true,
+		true,
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "if the address is registered, a sign-in link has been sent"})
+}
+
+// RedeemMagicLink всегда отвечает редиректом на разрешённый адрес возврата: при успехе
+// с refresh-cookie, при необходимости второго фактора — с mfa_token, при ошибке — с error.
+func (h *AuthHandler) RedeemMagicLink(c *gin.Context) {
+	binding, _ := c.Cookie(magicBindingCookie)
+
+	ctx, cancel := loginContext(c)
+	defer cancel()
+
+	result, returnURL, err := h.service.RedeemMagicLink(ctx, c.Param("token"), binding)
+
+	c.SetCookie(magicBindingCookie, "", -1, "/auth/magic", "", true, true)
+
+	if returnURL == "" {
+		returnURL, _ = h.service.MagicLinkReturnURL("")
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrMagicLinkDisabled) || returnURL == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		reason := "server_error"
+		switch {
+		case errors.Is(err, ErrMagicLinkInvalid):
+			reason = "invalid_link"
+		case errors.Is(err, ErrAccountDisabled):
+			reason = "account_disabled"
+		case errors.Is(err, ErrAccountLocked):
+			reason = "account_locked"
+		}
+		c.Redirect(http.StatusFound, withQuery(returnURL, url.Values{"error": {reason}}))
+		return
+	}
+
+	if result.MFAToken != "" {
+		c.Redirect(http.StatusFound, withQuery(returnURL, url.Values{
+			"mfa_token":   {result.MFAToken},
+			"mfa_methods": {strings.Join(result.MFAMethods, ",")},
+		}))
+		return
+	}
+
+	c.SetCookie(
+		"refresh_token",
+		result.RefreshToken,
+		60*60*24*30, // 30 дней
+		"/",
+		"",
+		true,
+		true,
+	)
+
+	c.Redirect(http.StatusFound, returnURL)
+}
+
+func withQuery(raw string, values url.Values) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	q := u.Query()
+	for k, v := range values {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
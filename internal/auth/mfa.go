@@ -0,0 +1,384 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	pendingMFAChallenge = "mfa_challenge"
+
+	recoveryCodeCount = 10
+	totpPeriod        = 30
+	totpQRSize        = 256
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode     = errors.New("invalid authentication code")
+
+	totpCodeRegex = regexp.MustCompile(`^[0-9]{6}$`)
+
+	totpOpts = totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+)
+
+// LoginResult: если MFAToken не пуст, пароль принят, но токены выдаются только после
+// второго фактора (VerifyMFA). DeviceToken — cookie доверенного устройства, если его попросили запомнить.
+type LoginResult struct {
+	AccessToken     string
+	RefreshToken    string
+	MFAToken        string
+	MFAMethods      []string
+	DeviceToken     string
+	DeviceExpiresAt time.Time
+}
+
+type TOTPSetup struct {
+	Secret    string `json:"secret"`
+	URI       string `json:"otpauth_uri"`
+	QRCodePNG string `json:"qr_png"`
+}
+
+// MFAChallenge: AMR — способы, которыми уже пройден первый фактор.
+type MFAChallenge struct {
+	UserID   string   `json:"user_id"`
+	AMR      []string `json:"amr"`
+	Attempts int      `json:"attempts"`
+}
+
+// SetupTOTP генерирует новый секрет; до ActivateTOTP он не действует.
+func (s *AuthService) SetupTOTP(ctx context.Context, userID string) (*TOTPSetup, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if row, err := s.storage.GetTOTP(ctx, userID); err == nil && row.EnabledAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	account := user.ID
+	if user.Email != nil {
+		account = *user.Email
+	} else if user.Phone != nil {
+		account = *user.Phone
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.mfaIssuer,
+		AccountName: account,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.box.Seal([]byte(key.Secret()))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.SaveTOTPSecret(ctx, userID, sealed); err != nil {
+		return nil, err
+	}
+
+	img, err := key.Image(totpQRSize, totpQRSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return &TOTPSetup{
+		Secret:    key.Secret(),
+		URI:       key.URL(),
+		QRCodePNG: base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// ActivateTOTP включает 2FA после первого верного кода и возвращает резервные коды;
+// показать их можно только один раз, в БД хранятся лишь хеши.
+func (s *AuthService) ActivateTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	row, err := s.storage.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if row.EnabledAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	step, err := s.matchTOTP(row, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, audit.Event{Actor: userID, Subject: userID, Action: audit.ActionTOTPEnabled})
+
+	return codes, nil
+}
+
+func (s *AuthService) DisableTOTP(ctx context.Context, userID, code string) error {
+	if err := s.confirmSecondFactor(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{Actor: userID, Subject: userID, Action: audit.ActionTOTPDisabled})
+
+	return nil
+}
+
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.confirmSecondFactor(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, audit.Event{Actor: userID, Subject: userID, Action: audit.ActionRecoveryCodesIssued})
+
+	return codes, nil
+}
+
+// VerifyMFA завершает вход по токену из LoginResult.MFAToken и TOTP- или резервному коду;
+// с remember устройство запоминается и в следующий раз второй фактор не спрашивается.
+func (s *AuthService) VerifyMFA(ctx context.Context, mfaToken, code string, remember bool) (*LoginResult, error) {
+	var challenge MFAChallenge
+	if err := s.storage.GetPending(ctx, pendingMFAChallenge, mfaToken, &challenge); err != nil {
+		return nil, err
+	}
+
+	// общий счётчик с паролем: перебор кода не обходит блокировку новым mfa_token
+	ip := requestctx.From(ctx).IP
+	attemptKey := UserKey(challenge.UserID, "")
+
+	if err := s.lockout.Check(ctx, attemptKey, ip); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSecondFactor(ctx, challenge.UserID, code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return nil, err
+		}
+
+		s.recordEvent(ctx, challenge.UserID, EventLogin, strings.Join(append(challenge.AMR, AMROTP), ","), "invalid_mfa_code")
+		s.auditLoginFailed(ctx, challenge.UserID, AMROTP, "invalid_mfa_code")
+		_, _ = s.lockout.Fail(ctx, attemptKey, ip)
+
+		challenge.Attempts++
+		if challenge.Attempts >= maxOTPAttempts {
+			_ = s.storage.DeletePending(ctx, pendingMFAChallenge, mfaToken)
+			return nil, ErrTooManyAttempts
+		}
+		if err := s.storage.UpdatePending(ctx, pendingMFAChallenge, mfaToken, challenge); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	_ = s.storage.DeletePending(ctx, pendingMFAChallenge, mfaToken)
+	_ = s.lockout.Reset(ctx, attemptKey)
+
+	result, err := s.finishLogin(ctx, challenge.UserID, newAuthContext(append(challenge.AMR, AMROTP, AMRMFA)...))
+	if err != nil {
+		return nil, err
+	}
+
+	if remember {
+		result.DeviceToken, result.DeviceExpiresAt, err = s.rememberDevice(ctx, challenge.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// completeLogin вызывается после проверки первого фактора: либо выдаёт токены,
+// либо, если включена 2FA и устройство не доверенное, создаёт MFA-челлендж.
+func (s *AuthService) completeLogin(ctx context.Context, userID string, amr ...string) (*LoginResult, error) {
+	// до запроса второго фактора, чтобы заблокированный аккаунт не получал mfa_token
+	if err := s.checkActive(ctx, userID, EventLogin, strings.Join(amr, ",")); err != nil {
+		return nil, err
+	}
+
+	methods, err := s.mfaMethods(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(methods) > 0 && !s.isTrustedDevice(ctx, userID) {
+		token, err := s.storage.SavePending(ctx, pendingMFAChallenge, MFAChallenge{UserID: userID, AMR: amr}, s.mfaChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFAToken: token, MFAMethods: methods}, nil
+	}
+
+	return s.finishLogin(ctx, userID, newAuthContext(amr...))
+}
+
+// mfaMethods — доступные пользователю вторые факторы: "totp" и/или "webauthn".
+func (s *AuthService) mfaMethods(ctx context.Context, userID string) ([]string, error) {
+	var methods []string
+
+	row, err := s.storage.GetTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, postgres.ErrTOTPNotFound) {
+		return nil, err
+	}
+	if err == nil && row.EnabledAt != nil {
+		methods = append(methods, "totp")
+	}
+
+	webauthnEnabled, err := s.hasWebAuthn(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if webauthnEnabled {
+		methods = append(methods, "webauthn")
+	}
+
+	return methods, nil
+}
+
+// checkSecondFactor принимает 6-значный TOTP-код или резервный код.
+func (s *AuthService) checkSecondFactor(ctx context.Context, userID, code string) error {
+	row, err := s.storage.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrTOTPNotFound) {
+			return ErrTOTPNotEnabled
+		}
+		return err
+	}
+
+	if row.EnabledAt == nil {
+		return ErrTOTPNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+
+	if !totpCodeRegex.MatchString(code) {
+		used, err := s.storage.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	step, err := s.matchTOTP(row, code)
+	if err != nil {
+		return err
+	}
+
+	// код из уже использованного временного шага не принимается повторно
+	fresh, err := s.storage.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// matchTOTP проверяет код с допуском ±1 шаг и возвращает номер совпавшего шага.
+func (s *AuthService) matchTOTP(row *postgres.UserTOTP, code string) (int64, error) {
+	secret, err := s.box.Open(row.SecretEncrypted)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, skew := range []int64{0, -1, 1} {
+		t := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+
+		expected, err := totp.GenerateCodeCustom(string(secret), t, totpOpts)
+		if err != nil {
+			return 0, err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return t.Unix() / totpPeriod, nil
+		}
+	}
+
+	return 0, ErrInvalidMFACode
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))[:10]
+		code := s[:5] + "-" + s[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode: коды случайные и длинные, поэтому достаточно SHA-256 без соли.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
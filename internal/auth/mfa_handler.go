@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// SecondFactorRequest: Code — 6-значный TOTP-код или резервный код вида xxxxx-xxxxx.
+type SecondFactorRequest struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type VerifyMFARequest struct {
+	MFAToken       string `json:"mfa_token" binding:"required,uuid"`
+	Code           string `json:"code" binding:"required,max=32"`
+	RememberDevice bool   `json:"remember_device"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (h *AuthHandler) SetupTOTP(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	setup, err := h.service.SetupTOTP(ctx, c.GetString("user_id"))
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+func (h *AuthHandler) ActivateTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	codes, err := h.service.ActivateTOTP(ctx, c.GetString("user_id"), req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	var req SecondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.DisableTOTP(ctx, c.GetString("user_id"), req.Code); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req SecondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	codes, err := h.service.RegenerateRecoveryCodes(ctx, c.GetString("user_id"), req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.service.VerifyMFA(ctx, req.MFAToken, req.Code, req.RememberDevice)
+	if err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
+
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrPendingNotFound), errors.Is(err, ErrInvalidMFACode),
+			errors.Is(err, ErrTOTPNotEnabled):
+			status = http.StatusUnauthorized
+		case errors.Is(err, ErrTooManyAttempts):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, ErrAccountDisabled):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrAccountLocked):
+			status = http.StatusLocked
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondLogin(c, result)
+}
+
+func respondMFAError(c *gin.Context, err error) {
+	if respondThrottled(c, err) {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidMFACode):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrTOTPAlreadyEnabled), errors.Is(err, ErrTOTPNotEnabled):
+		status = http.StatusConflict
+	case errors.Is(err, postgres.ErrTOTPNotFound):
+		status = http.StatusConflict
+		err = ErrTOTPNotEnabled
+	case errors.Is(err, postgres.ErrUserNotFound):
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
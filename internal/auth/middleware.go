@@ -1,12 +1,29 @@
 package auth
 
 import (
+	"ahub/internal/audit"
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
+// StatusChecker сообщает, может ли пользователь с действующим токеном продолжать работу.
+type StatusChecker interface {
+	CheckStatus(ctx context.Context, userID string) error
+}
+
+// AuthMiddleware проверяет access-токен и статус аккаунта: токены отключённого,
+// заблокированного или удалённого пользователя отклоняются.
+func AuthMiddleware(jwtManager *JWTManager, statuses StatusChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -16,14 +33,144 @@ func AuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
-		userID, err := jwtManager.ParseAccessToken(token)
+		claims, err := jwtManager.ParseAccessToken(token)
 		if err != nil {
 			c.AbortWithStatusJSON(401, gin.H{"error": "invalid token"})
 			return
 		}
 
-		c.Set("user_id", userID)
+		if err := checkPrincipal(c, statuses, claims); err != nil {
+			switch {
+			case errors.Is(err, ErrAccountDisabled), errors.Is(err, ErrAccountLocked):
+				c.AbortWithStatusJSON(401, gin.H{"error": err.Error()})
+			case errors.Is(err, postgres.ErrUserNotFound):
+				c.AbortWithStatusJSON(401, gin.H{"error": "invalid token"})
+			default:
+				c.AbortWithStatusJSON(503, gin.H{"error": "server is busy, retry later"})
+			}
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("auth_time", claims.Time)
+		c.Set("amr", claims.Methods)
+		c.Set("roles", claims.Roles)
+		c.Set("permissions", claims.Permissions)
+		if claims.Impersonator != "" {
+			c.Set("impersonator", claims.Impersonator)
+			c.Request = c.Request.WithContext(requestctx.WithImpersonator(c.Request.Context(), claims.Impersonator))
+		}
+
+		c.Next()
+	}
+}
+
+// checkPrincipal проверяет статус пользователя, а для токена имперсонации — и администратора:
+// отключённый сотрудник теряет доступ сразу, а не по истечении токена.
+func checkPrincipal(c *gin.Context, statuses StatusChecker, claims *AccessClaims) error {
+	if err := statuses.CheckStatus(c.Request.Context(), claims.UserID); err != nil {
+		return err
+	}
+
+	if claims.Impersonator == "" || claims.Impersonator == audit.ActorAdmin {
+		return nil
+	}
+	return statuses.CheckStatus(c.Request.Context(), claims.Impersonator)
+}
+
+// ImpersonationAuditor пишет в журнал аудита запросы, выполненные по токену имперсонации.
+type ImpersonationAuditor interface {
+	AuditImpersonatedRequest(ctx context.Context, impersonator, userID, method, route string, status int)
+}
+
+// AuditImpersonation ставится после AuthMiddleware (или AdminAuth) и до DenyImpersonatedWrites:
+// каждый запрос по токену имперсонации попадает в аудит вместе с итоговым статусом, в том числе отклонённый.
+func AuditImpersonation(auditor ImpersonationAuditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		impersonator := c.GetString("impersonator")
+		if impersonator == "" {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		auditor.AuditImpersonatedRequest(c.Request.Context(), impersonator, c.GetString("user_id"), c.Request.Method, route, c.Writer.Status())
+	}
+}
+
+// DenyImpersonatedWrites ставится после AuthMiddleware: токен имперсонации даёт только просмотр,
+// изменяющие запросы от его имени отклоняются.
+func DenyImpersonatedWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("impersonator") == "" {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+		default:
+			c.AbortWithStatusJSON(403, gin.H{"error": "not allowed while impersonating"})
+		}
+	}
+}
+
+// RequireRecentAuth ставится после AuthMiddleware: пропускает, только если пользователь
+// подтверждал личность не раньше maxAge назад. Иначе — 401 в формате RFC 9470,
+// клиент должен пройти /auth/reauthenticate и повторить запрос с новым токеном.
+func RequireRecentAuth(maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authTime := c.GetTime("auth_time")
+		if authTime.IsZero() || time.Since(authTime) > maxAge {
+			c.Header("WWW-Authenticate", fmt.Sprintf(
+				`Bearer error="insufficient_user_authentication", error_description="recent authentication required", max_age=%d`,
+				int(maxAge.Seconds()),
+			))
+			c.AbortWithStatusJSON(401, gin.H{"error": "recent authentication required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission ставится после AuthMiddleware (или AdminAuth) и пропускает,
+// только если в токене есть нужное право.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("admin_key") || slices.Contains(c.GetStringSlice("permissions"), permission) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(403, gin.H{"error": "forbidden", "required_permission": permission})
+	}
+}
+
+// AdminAuth пускает в административный API по токену пользователя — права затем проверяет
+// RequirePermission — или по служебному ключу из X-Admin-Key, которому доступно всё.
+// Пустой ключ в конфиге отключает вход по ключу.
+func AdminAuth(jwtManager *JWTManager, statuses StatusChecker, apiKey string) gin.HandlerFunc {
+	authenticate := AuthMiddleware(jwtManager, statuses)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Admin-Key")
+		if key == "" {
+			authenticate(c)
+			return
+		}
+
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			c.AbortWithStatusJSON(403, gin.H{"error": "forbidden"})
+			return
+		}
 
+		c.Set("admin_key", true)
 		c.Next()
 	}
 }
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+)
+
+const maxOTPAttempts = 5
+
+var (
+	ErrInvalidCode     = errors.New("invalid confirmation code")
+	ErrTooManyAttempts = errors.New("too many attempts, request a new code")
+)
+
+// otpState хранится внутри отложенного действия вместе с его данными.
+type otpState struct {
+	OTP      string `json:"otp"`
+	Attempts int    `json:"attempts"`
+}
+
+func (o *otpState) state() *otpState { return o }
+
+type pendingCode interface {
+	state() *otpState
+}
+
+// checkCode сверяет код и считает неудачные попытки; после maxOTPAttempts действие удаляется.
+func (s *AuthService) checkCode(ctx context.Context, kind, token, code string, data pendingCode) error {
+	st := data.state()
+
+	if st.Attempts >= maxOTPAttempts {
+		_ = s.storage.DeletePending(ctx, kind, token)
+		return ErrTooManyAttempts
+	}
+
+	if subtle.ConstantTimeCompare([]byte(code), []byte(st.OTP)) == 1 {
+		return nil
+	}
+
+	st.Attempts++
+	if st.Attempts >= maxOTPAttempts {
+		_ = s.storage.DeletePending(ctx, kind, token)
+		return ErrTooManyAttempts
+	}
+
+	if err := s.storage.UpdatePending(ctx, kind, token, data); err != nil {
+		return err
+	}
+
+	return ErrInvalidCode
+}
+
+func generationOTP() string {
+	const digits = "0123456789"
+	otp := make([]byte, 6)
+	for i := range otp {
+		otp[i] = digits[randomInt(len(digits))]
+	}
+
+	return string(otp)
+}
+
+func randomInt(max int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		panic(err)
+	}
+	return int(n.Int64())
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"ahub/internal/identifier"
+	"ahub/internal/requestctx"
+	"context"
+	"errors"
+)
+
+const pendingOTPLogin = "otp_login"
+
+var ErrOTPLoginDisabled = errors.New("login by one-time code is disabled")
+
+type OTPLoginData struct {
+	otpState
+	UserID string `json:"user_id"`
+	Method string `json:"method"`
+	// AttemptKey — счётчик блокировки, общий с входом по паролю
+	AttemptKey string `json:"attempt_key"`
+}
+
+// StartOTPLogin отправляет код на email или телефон. Как и сброс пароля, всегда возвращает
+// токен: по ответу нельзя узнать, зарегистрирован ли адрес.
+func (s *AuthService) StartOTPLogin(ctx context.Context, login string) (string, error) {
+	if !s.otpLogin {
+		return "", ErrOTPLoginDisabled
+	}
+
+	id, err := s.ids.Normalize(login)
+	if err != nil {
+		return "", err
+	}
+
+	data := OTPLoginData{otpState: otpState{OTP: generationOTP()}, Method: AMREmail}
+	if id.Kind == identifier.Phone {
+		data.Method = AMRSMS
+	}
+
+	data.AttemptKey = UserKey("", id.Value)
+	if user, err := s.logins.GetUserByLogin(ctx, id.Value); err == nil {
+		data.UserID = user.ID
+		data.AttemptKey = UserKey(user.ID, "")
+	}
+
+	// заблокированному логину код не отправляется; для неизвестного логина счётчик свой,
+	// поэтому ответ не выдаёт, зарегистрирован ли адрес
+	if err := s.lockout.Check(ctx, data.AttemptKey, requestctx.From(ctx).IP); err != nil {
+		return "", err
+	}
+
+	token, err := s.storage.SavePending(ctx, pendingOTPLogin, data, s.otpTTL)
+	if err != nil {
+		return "", err
+	}
+
+	if data.UserID != "" {
+		s.sendAsync(ctx, id.Value, "Sign-in code", "Your sign-in code: "+data.OTP)
+	}
+
+	return token, nil
+}
+
+// VerifyOTPLogin выдаёт ту же пару токенов, что и Login; при включённой 2FA код
+// заменяет только пароль, второй фактор по-прежнему запрашивается.
+func (s *AuthService) VerifyOTPLogin(ctx context.Context, token, code string) (*LoginResult, error) {
+	if !s.otpLogin {
+		return nil, ErrOTPLoginDisabled
+	}
+
+	var data OTPLoginData
+	if err := s.storage.GetPending(ctx, pendingOTPLogin, token, &data); err != nil {
+		return nil, err
+	}
+
+	ip := requestctx.From(ctx).IP
+
+	if err := s.lockout.Check(ctx, data.AttemptKey, ip); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCode(ctx, pendingOTPLogin, token, code, &data); err != nil {
+		if errors.Is(err, ErrInvalidCode) || errors.Is(err, ErrTooManyAttempts) {
+			_, _ = s.lockout.Fail(ctx, data.AttemptKey, ip)
+		}
+		return nil, err
+	}
+
+	if data.UserID == "" {
+		_, _ = s.lockout.Fail(ctx, data.AttemptKey, ip)
+		return nil, ErrInvalidCode
+	}
+
+	_ = s.storage.DeletePending(ctx, pendingOTPLogin, token)
+
+	result, err := s.completeLogin(ctx, data.UserID, data.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	// как в Login: при 2FA счётчик сбрасывает VerifyMFA
+	if result.MFAToken == "" {
+		_ = s.lockout.Reset(ctx, data.AttemptKey)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"ahub/internal/identifier"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StartOTPLoginRequest struct {
+	Login string `json:"login" binding:"required,max=254,login"`
+}
+
+type StartOTPLoginResponse struct {
+	LoginToken string `json:"login_token"`
+}
+
+type VerifyOTPLoginRequest struct {
+	Token string `json:"token" binding:"required,uuid"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
+}
+
+func (h *AuthHandler) StartOTPLogin(c *gin.Context) {
+	var req StartOTPLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	token, err := h.service.StartOTPLogin(ctx, req.Login)
+	if err != nil {
+		respondOTPLoginError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, StartOTPLoginResponse{LoginToken: token})
+}
+
+func (h *AuthHandler) VerifyOTPLogin(c *gin.Context) {
+	var req VerifyOTPLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := loginContext(c)
+	defer cancel()
+
+	result, err := h.service.VerifyOTPLogin(ctx, req.Token, req.Code)
+	if err != nil {
+		respondOTPLoginError(c, err)
+		return
+	}
+
+	respondLogin(c, result)
+}
+
+func respondOTPLoginError(c *gin.Context, err error) {
+	if respondThrottled(c, err) {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrOTPLoginDisabled):
+		status = http.StatusNotFound
+	case errors.Is(err, identifier.ErrInvalidEmail), errors.Is(err, identifier.ErrInvalidPhone):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrPendingNotFound), errors.Is(err, ErrInvalidCode):
+		status = http.StatusUnauthorized
+	case errors.Is(err, ErrTooManyAttempts):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, ErrAccountDisabled):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrAccountLocked):
+		status = http.StatusLocked
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/storage/postgres"
+	"context"
+)
+
+const pendingPasswordReset = "password_reset"
+
+type PasswordResetData struct {
+	otpState
+	UserID string `json:"user_id"`
+}
+
+// ChangePassword завершает все сессии пользователя и выдаёт новую пару токенов текущему клиенту.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (string, string, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.confirmPassword(ctx, user, currentPassword); err != nil {
+		return "", "", err
+	}
+
+	if err := s.setPassword(ctx, user, newPassword, "password_changed"); err != nil {
+		return "", "", err
+	}
+
+	s.recordAudit(ctx, audit.Event{Actor: userID, Subject: userID, Action: audit.ActionPasswordChanged})
+
+	return s.issueTokens(ctx, userID, newAuthContext(AMRPassword))
+}
+
+// StartPasswordReset всегда возвращает токен, даже если логин не найден: по ответу и его
+// времени нельзя узнать, зарегистрирован ли адрес. Код отправляется только существующему пользователю.
+func (s *AuthService) StartPasswordReset(ctx context.Context, login string) (string, error) {
+	data := PasswordResetData{otpState: otpState{OTP: generationOTP()}}
+
+	id, err := s.ids.Normalize(login)
+	if err != nil {
+		return "", err
+	}
+
+	if user, err := s.logins.GetUserByLogin(ctx, id.Value); err == nil {
+		data.UserID = user.ID
+	}
+
+	token, err := s.storage.SavePending(ctx, pendingPasswordReset, data, s.otpTTL)
+	if err != nil {
+		return "", err
+	}
+
+	if data.UserID != "" {
+		s.sendAsync(ctx, id.Value, "Password reset", "Your password reset code: "+data.OTP)
+	}
+
+	return token, nil
+}
+
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, token, code, newPassword string) error {
+	var data PasswordResetData
+	if err := s.storage.GetPending(ctx, pendingPasswordReset, token, &data); err != nil {
+		return err
+	}
+
+	if err := s.checkCode(ctx, pendingPasswordReset, token, code, &data); err != nil {
+		return err
+	}
+
+	// сброс для несуществующего логина никогда не подтверждается
+	if data.UserID == "" {
+		return ErrInvalidCode
+	}
+
+	user, err := s.storage.GetUser(ctx, data.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, user, newPassword, "password_reset"); err != nil {
+		return err
+	}
+
+	_ = s.storage.DeletePending(ctx, pendingPasswordReset, token)
+
+	s.recordAudit(ctx, audit.Event{Actor: user.ID, Subject: user.ID, Action: audit.ActionPasswordReset})
+
+	return nil
+}
+
+// setPassword меняет пароль и завершает все сессии; reason попадает в session.revoked.
+func (s *AuthService) setPassword(ctx context.Context, user *postgres.User, newPassword, reason string) error {
+	if err := s.policy.Check(newPassword, personalData(user)...); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(ctx, newPassword)
+	if err != nil {
+		return err
+	}
+
+	// вместе с паролем сбрасываются все сессии и доверие к устройствам
+	err = s.storage.InTx(ctx, func(tx *AuthStorage) error {
+		if err := tx.UpdatePassword(ctx, user.ID, hash); err != nil {
+			return err
+		}
+		return s.revokeAllSessions(ctx, tx, user.ID, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, to := range []*string{user.Email, user.Phone} {
+		if to != nil {
+			_ = s.sender.Send(ctx, *to,
+				"Your password was changed",
+				"The password on your account was changed and all sessions were signed out. If this wasn't you, reset your password.",
+			)
+		}
+	}
+
+	return nil
+}
+
+func personalData(user *postgres.User) []string {
+	personal := []string{user.FirstName, user.LastName}
+	if user.Email != nil {
+		personal = append(personal, *user.Email)
+	}
+	if user.Phone != nil {
+		personal = append(personal, *user.Phone)
+	}
+	return personal
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"ahub/internal/identifier"
+	"ahub/internal/password"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required,max=1024"`
+	NewPassword     string `json:"new_password" binding:"required,max=1024"`
+}
+
+type ResetPasswordRequest struct {
+	Login string `json:"login" binding:"required,max=254,login"`
+}
+
+type ResetPasswordResponse struct {
+	ResetToken string `json:"reset_token"`
+}
+
+type ConfirmResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required,uuid"`
+	Code        string `json:"code" binding:"required,len=6,numeric"`
+	NewPassword string `json:"new_password" binding:"required,max=1024"`
+}
+
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	accessToken, refreshToken, err := h.service.ChangePassword(ctx, c.GetString("user_id"), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		respondPasswordError(c, "new_password", err)
+		return
+	}
+
+	c.SetCookie(
+		"refresh_token",
+		refreshToken,
+		60*60*24*30, // 30 дней
+		"/",
+		"",
+		true,
+		true,
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+	})
+}
+
+func (h *AuthHandler) StartPasswordReset(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	token, err := h.service.StartPasswordReset(ctx, req.Login)
+	if err != nil {
+		respondPasswordError(c, "login", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResetPasswordResponse{ResetToken: token})
+}
+
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req ConfirmResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.ConfirmPasswordReset(ctx, req.Token, req.Code, req.NewPassword); err != nil {
+		respondPasswordError(c, "new_password", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password updated"})
+}
+
+func respondPasswordError(c *gin.Context, field string, err error) {
+	var perr *password.PolicyError
+	if errors.As(err, &perr) {
+		respondPolicyError(c, field, perr)
+		return
+	}
+
+	if respondOverloaded(c, err) || respondThrottled(c, err) {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidPassword):
+		status = http.StatusForbidden
+	case errors.Is(err, identifier.ErrInvalidEmail), errors.Is(err, identifier.ErrInvalidPhone),
+		errors.Is(err, ErrPendingNotFound), errors.Is(err, ErrInvalidCode):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrTooManyAttempts):
+		status = http.StatusTooManyRequests
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
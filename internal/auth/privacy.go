@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/events"
+	"ahub/storage/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var ErrInvalidPassword = errors.New("invalid password")
+
+// exportBatch — размер страницы при выгрузке истории входов и журнала аудита.
+const exportBatch = 500
+
+type AccountExport struct {
+	ExportedAt   time.Time          `json:"exported_at"`
+	Profile      ExportProfile      `json:"profile"`
+	Sessions     []ExportSession    `json:"sessions"`
+	Activity     []ActivityEvent    `json:"activity"`
+	AuditHistory []ExportAuditEntry `json:"audit_history"`
+}
+
+type ExportProfile struct {
+	ID              string     `json:"id"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Phone           *string    `json:"phone"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type ExportSession struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ExportAuditEntry struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	IP        string          `json:"ip,omitempty"`
+	Metadata  json.RawMessage `json:"metadata"`
+}
+
+// DeleteAccount после проверки пароля помечает аккаунт удалённым; данные стираются Purger-ом
+// по истечении deletionGrace.
+func (s *AuthService) DeleteAccount(ctx context.Context, userID, password string) (time.Time, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.confirmPassword(ctx, user, password); err != nil {
+		return time.Time{}, err
+	}
+
+	return s.scheduleDeletion(ctx, userID, user)
+}
+
+// scheduleDeletion помечает аккаунт удалённым от имени actor: сам пользователь или администратор.
+// Отключённый или заблокированный аккаунт не удаляется: RestoreUser вернул бы его в active.
+func (s *AuthService) scheduleDeletion(ctx context.Context, actor string, user *postgres.User) (time.Time, error) {
+	if user.Status != postgres.UserStatusActive {
+		return time.Time{}, postgres.ErrStatusTransition
+	}
+
+	purgeAfter := time.Now().Add(s.deletionGrace)
+
+	err := s.accounts.InAccountTx(ctx, func(tx accountTx) error {
+		if err := tx.SoftDeleteUser(ctx, user.ID, purgeAfter); err != nil {
+			return err
+		}
+		return s.publish(ctx, tx, events.UserDeleted, events.UserDeletedData{UserID: user.ID, PurgeAfter: purgeAfter})
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.accounts.InvalidateStatus(ctx, user.ID); err != nil {
+		s.storage.bd.Log.Warn("invalidate user status", slog.String("error", err.Error()))
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:    actor,
+		Subject:  user.ID,
+		Action:   audit.ActionDeleted,
+		Metadata: map[string]any{"purge_after": purgeAfter.UTC().Format(time.RFC3339)},
+	})
+
+	for _, to := range []*string{user.Email, user.Phone} {
+		if to != nil {
+			_ = s.sender.Send(ctx, *to,
+				"Your account is scheduled for deletion",
+				"Your account and its data will be permanently deleted after "+purgeAfter.Format(time.RFC1123)+".",
+			)
+		}
+	}
+
+	return purgeAfter, nil
+}
+
+func (s *AuthService) ExportAccount(ctx context.Context, userID string) (*AccountExport, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.storage.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]ExportSession, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, ExportSession{CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt})
+	}
+
+	activity, err := s.exportActivity(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	auditHistory, err := s.exportAuditHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccountExport{
+		ExportedAt:   time.Now().UTC(),
+		Activity:     activity,
+		AuditHistory: auditHistory,
+		Profile: ExportProfile{
+			ID:              user.ID,
+			FirstName:       user.FirstName,
+			LastName:        user.LastName,
+			Email:           user.Email,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+			Phone:           user.Phone,
+			PhoneVerifiedAt: user.PhoneVerifiedAt,
+			CreatedAt:       user.CreatedAt,
+		},
+		Sessions: sessions,
+	}, nil
+}
+
+// exportActivity выгружает всю историю входов, которая ещё не удалена по сроку хранения.
+func (s *AuthService) exportActivity(ctx context.Context, userID string) ([]ActivityEvent, error) {
+	activity := []ActivityEvent{}
+
+	cursor := ""
+	for {
+		page, err := s.listActivity(ctx, userID, cursor, exportBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		activity = append(activity, page.Events...)
+		if page.NextCursor == "" {
+			return activity, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// exportAuditHistory выгружает записи журнала аудита, относящиеся к пользователю.
+func (s *AuthService) exportAuditHistory(ctx context.Context, userID string) ([]ExportAuditEntry, error) {
+	history := []ExportAuditEntry{}
+
+	cursor := ""
+	for {
+		page, err := s.auditLog.Query(ctx, audit.Filter{Subject: userID, Cursor: cursor, Limit: exportBatch})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range page.Entries {
+			history = append(history, ExportAuditEntry{
+				CreatedAt: e.CreatedAt,
+				Actor:     e.Actor,
+				Action:    e.Action,
+				IP:        e.IP,
+				Metadata:  e.Metadata,
+			})
+		}
+
+		if page.NextCursor == "" {
+			return history, nil
+		}
+		cursor = page.NextCursor
+	}
+}
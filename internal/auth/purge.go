@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger периодически окончательно удаляет аккаунты, у которых истёк срок ожидания после удаления,
+// а также события входа и записи аудита старше срока хранения.
+type Purger struct {
+	storage        *AuthStorage
+	auditLog       *audit.Log
+	interval       time.Duration
+	retention      time.Duration
+	auditRetention time.Duration
+	log            *slog.Logger
+}
+
+func NewPurger(storage *AuthStorage, auditLog *audit.Log, interval, retention, auditRetention time.Duration, log *slog.Logger) *Purger {
+	return &Purger{
+		storage:        storage,
+		auditLog:       auditLog,
+		interval:       interval,
+		retention:      retention,
+		auditRetention: auditRetention,
+		log:            log,
+	}
+}
+
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	n, err := p.storage.PurgeDeletedUsers(ctx, now)
+	if err != nil {
+		p.log.Error("purge deleted users", slog.String("error", err.Error()))
+	} else if n > 0 {
+		p.log.Info("purged deleted users", slog.Int64("count", n))
+	}
+
+	if p.retention > 0 {
+		n, err = p.storage.PurgeLoginEvents(ctx, now.Add(-p.retention))
+		if err != nil {
+			p.log.Error("purge login events", slog.String("error", err.Error()))
+		} else if n > 0 {
+			p.log.Info("purged login events", slog.Int64("count", n))
+		}
+	}
+
+	if p.auditRetention > 0 {
+		n, err = p.auditLog.Prune(ctx, now.Add(-p.auditRetention))
+		if err != nil {
+			p.log.Error("prune audit log", slog.String("error", err.Error()))
+		} else if n > 0 {
+			p.log.Info("pruned audit log", slog.Int64("count", n))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleUser выдаётся каждому новому пользователю.
+const RoleUser = "user"
+
+const (
+	PermUsersRead    = "users:read"
+	PermUsersWrite   = "users:write"
+	PermUsersUnlock  = "users:unlock"
+	PermImpersonate  = "users:impersonate"
+	PermRolesWrite   = "roles:write"
+	PermAuditRead    = "audit:read"
+	PermWebhooksRead = "webhooks:read"
+	PermMetricsRead  = "metrics:read"
+)
+
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func (s *AuthService) userAccess(ctx context.Context, userID string) (Access, error) {
+	roles, permissions, err := s.storage.GetUserAccess(ctx, userID)
+	if err != nil {
+		return Access{}, err
+	}
+	return Access{Roles: roles, Permissions: permissions}, nil
+}
+
+func (s *AuthService) ListRoles(ctx context.Context) ([]RoleInfo, error) {
+	roles, permissions, err := s.storage.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]RoleInfo, 0, len(roles))
+	for _, r := range roles {
+		perms := permissions[r.Name]
+		if perms == nil {
+			perms = []string{}
+		}
+		result = append(result, RoleInfo{Name: r.Name, Description: r.Description, Permissions: perms})
+	}
+
+	return result, nil
+}
+
+func (s *AuthService) AssignRole(ctx context.Context, actor, userID, role string) error {
+	if err := s.storage.AssignRole(ctx, userID, role); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:    actor,
+		Subject:  userID,
+		Action:   audit.ActionRoleGranted,
+		Metadata: map[string]any{"role": role},
+	})
+
+	return nil
+}
+
+func (s *AuthService) RevokeRole(ctx context.Context, actor, userID, role string) error {
+	if err := s.storage.RevokeRole(ctx, userID, role); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:    actor,
+		Subject:  userID,
+		Action:   audit.ActionRoleRevoked,
+		Metadata: map[string]any{"role": role},
+	})
+
+	return nil
+}
+
+// adminActor — кто выполняет административное действие: пользователь из токена
+// или служебный ключ.
+func adminActor(c *gin.Context) string {
+	if c.GetBool("admin_key") {
+		return audit.ActorAdmin
+	}
+	return c.GetString("user_id")
+}
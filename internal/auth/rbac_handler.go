@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required,max=64"`
+}
+
+func (h *AuthHandler) ListRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	roles, err := h.service.ListRoles(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *AuthHandler) AssignRole(c *gin.Context) {
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.AssignRole(ctx, adminActor(c), c.Param("id"), req.Role); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role assigned"})
+}
+
+func (h *AuthHandler) RevokeRole(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RevokeRole(ctx, adminActor(c), c.Param("id"), c.Param("role")); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role revoked"})
+}
+
+func respondRoleError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, postgres.ErrUserNotFound), errors.Is(err, postgres.ErrRoleNotFound),
+		errors.Is(err, postgres.ErrRoleNotAssigned):
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
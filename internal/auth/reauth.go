@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+)
+
+var ErrReauthMethod = errors.New("password or authentication code is required")
+
+// Reauthenticate повторно проверяет пароль или второй фактор и выдаёт короткоживущий
+// токен со свежим auth_time для операций под RequireRecentAuth.
+func (s *AuthService) Reauthenticate(ctx context.Context, userID, password, code string) (string, error) {
+	var amr []string
+
+	switch {
+	case password != "":
+		user, err := s.storage.GetUser(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+
+		if err := s.confirmPassword(ctx, user, password); err != nil {
+			return "", err
+		}
+		amr = []string{AMRPassword}
+
+	case code != "":
+		if err := s.confirmSecondFactor(ctx, userID, code); err != nil {
+			return "", err
+		}
+		amr = []string{AMROTP}
+
+	default:
+		return "", ErrReauthMethod
+	}
+
+	access, err := s.userAccess(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return s.jwt.GenerateElevatedToken(userID, newAuthContext(amr...), access)
+}
+
+// confirmPassword повторно проверяет пароль вошедшего пользователя под общим счётчиком
+// блокировки: украденный access-токен не должен давать неограниченный перебор пароля.
+func (s *AuthService) confirmPassword(ctx context.Context, user *postgres.User, password string) error {
+	ip := requestctx.From(ctx).IP
+	attemptKey := UserKey(user.ID, "")
+
+	if err := s.lockout.Check(ctx, attemptKey, ip); err != nil {
+		return err
+	}
+
+	if err := s.verifyPassword(ctx, user.ID, password, user.PasswordHash); err != nil {
+		if !isOverloaded(err) {
+			_, _ = s.lockout.Fail(ctx, attemptKey, ip)
+		}
+		return err
+	}
+
+	_ = s.lockout.Reset(ctx, attemptKey)
+	return nil
+}
+
+// confirmSecondFactor — то же для TOTP- или резервного кода.
+func (s *AuthService) confirmSecondFactor(ctx context.Context, userID, code string) error {
+	ip := requestctx.From(ctx).IP
+	attemptKey := UserKey(userID, "")
+
+	if err := s.lockout.Check(ctx, attemptKey, ip); err != nil {
+		return err
+	}
+
+	if err := s.checkSecondFactor(ctx, userID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			_, _ = s.lockout.Fail(ctx, attemptKey, ip)
+		}
+		return err
+	}
+
+	_ = s.lockout.Reset(ctx, attemptKey)
+	return nil
+}
@@ -1,19 +1,84 @@
 package auth
 
-import "github.com/gin-gonic/gin"
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(r *gin.Engine, h *AuthHandler, jwtManager *JWTManager, adminKey string, recentAuth time.Duration, rateLimit gin.HandlerFunc) {
+	// чувствительные операции требуют недавнего входа или /auth/reauthenticate
+	recent := RequireRecentAuth(recentAuth)
 
-func RegisterRoutes(r *gin.Engine, h *AuthHandler, jwtManager *JWTManager) {
 	public := r.Group("/auth")
+	public.Use(rateLimit)
 	{
 		public.POST("/register", h.StartRegistration)
 		public.POST("/register-confirm", h.CreateNewUser)
 		public.POST("/login", h.Login)
+		public.POST("/password/reset", h.StartPasswordReset)
+		public.POST("/password/reset/confirm", h.ConfirmPasswordReset)
+		public.POST("/refresh", h.Refresh)
+		public.POST("/otp/start", h.StartOTPLogin)
+		public.POST("/otp/verify", h.VerifyOTPLogin)
+		public.POST("/magic/start", h.StartMagicLink)
+		public.GET("/magic/:token", h.RedeemMagicLink)
+		public.GET("/not-me/:token", h.ConfirmRevokeFromAlert)
+		public.POST("/not-me/:token", h.RevokeFromAlert)
+		public.POST("/2fa/verify", h.VerifyMFA)
+		public.POST("/webauthn/login/begin", h.BeginWebAuthnLogin)
+		public.POST("/webauthn/login/finish", h.FinishWebAuthnLogin)
 	}
 
 	protected := r.Group("/auth")
-	protected.Use(AuthMiddleware(jwtManager))
+	protected.Use(AuthMiddleware(jwtManager, h.service), AuditImpersonation(h.service), DenyImpersonatedWrites(), rateLimit)
 	{
-		protected.POST("/refresh", h.Refresh)
 		protected.POST("/logout", h.Logout)
+		protected.POST("/reauthenticate", h.Reauthenticate)
+		protected.POST("/2fa/totp/setup", recent, h.SetupTOTP)
+		protected.POST("/2fa/totp/verify", recent, h.ActivateTOTP)
+		protected.DELETE("/2fa/totp", recent, h.DisableTOTP)
+		protected.POST("/2fa/recovery-codes", recent, h.RegenerateRecoveryCodes)
+		protected.POST("/webauthn/register/begin", recent, h.BeginWebAuthnRegistration)
+		protected.POST("/webauthn/register/finish", recent, h.FinishWebAuthnRegistration)
+	}
+
+	users := r.Group("/users/me")
+	users.Use(AuthMiddleware(jwtManager, h.service), AuditImpersonation(h.service), DenyImpersonatedWrites(), rateLimit)
+	{
+		users.DELETE("", recent, h.DeleteAccount)
+		users.GET("/export", h.ExportAccount)
+		users.POST("/password", recent, h.ChangePassword)
+		users.POST("/email", recent, h.StartEmailChange)
+		users.POST("/email/confirm", recent, h.ConfirmEmailChange)
+		users.POST("/phone", recent, h.StartPhoneChange)
+		users.POST("/phone/confirm", recent, h.ConfirmPhoneChange)
+		users.POST("/identifiers", recent, h.StartAddIdentifier)
+		users.POST("/identifiers/confirm", recent, h.ConfirmAddIdentifier)
+		users.GET("/sessions", h.ListSessions)
+		users.DELETE("/sessions/:id", h.RevokeSession)
+		users.GET("/activity", h.ListActivity)
+		users.GET("/trusted-devices", h.ListTrustedDevices)
+		users.DELETE("/trusted-devices", h.RevokeAllTrustedDevices)
+		users.DELETE("/trusted-devices/:id", h.RevokeTrustedDevice)
+	}
+
+	admin := r.Group("/admin")
+	admin.Use(AdminAuth(jwtManager, h.service, adminKey), AuditImpersonation(h.service))
+	{
+		admin.GET("/users", RequirePermission(PermUsersRead), h.ListUsers)
+		admin.GET("/users/:id", RequirePermission(PermUsersRead), h.GetUser)
+		admin.POST("/users/:id/disable", RequirePermission(PermUsersWrite), h.DisableUser)
+		admin.POST("/users/:id/enable", RequirePermission(PermUsersWrite), h.EnableUser)
+		admin.POST("/users/:id/lock", RequirePermission(PermUsersWrite), h.LockUser)
+		admin.POST("/users/:id/restore", RequirePermission(PermUsersWrite), h.RestoreUser)
+		admin.POST("/users/:id/password-reset", RequirePermission(PermUsersWrite), h.ForcePasswordReset)
+		admin.DELETE("/users/:id/sessions", RequirePermission(PermUsersWrite), h.RevokeUserSessions)
+		admin.DELETE("/users/:id", RequirePermission(PermUsersWrite), h.DeleteUser)
+		admin.POST("/users/:id/unlock", RequirePermission(PermUsersUnlock), h.UnlockUser)
+		admin.POST("/users/:id/impersonate", RequirePermission(PermImpersonate), h.Impersonate)
+		admin.GET("/roles", RequirePermission(PermUsersRead), h.ListRoles)
+		admin.POST("/users/:id/roles", RequirePermission(PermRolesWrite), h.AssignRole)
+		admin.DELETE("/users/:id/roles/:role", RequirePermission(PermRolesWrite), h.RevokeRole)
 	}
 }
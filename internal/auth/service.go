@@ -1,45 +1,216 @@
 package auth
 
 import (
+	"ahub/internal/audit"
+	"ahub/internal/config"
+	"ahub/internal/events"
+	"ahub/internal/identifier"
+	"ahub/internal/notify"
+	"ahub/internal/password"
+	"ahub/internal/requestctx"
+	"ahub/internal/secretbox"
 	_ "ahub/storage"
+	"ahub/storage/postgres"
 	"context"
+	"database/sql"
 	"errors"
+	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// loginStore — то, что проверка пароля читает и пишет до выдачи токенов.
+type loginStore interface {
+	GetUserByLogin(ctx context.Context, login string) (*postgres.UserInfo, error)
+	CreateLoginEvent(ctx context.Context, event *postgres.LoginEvent) error
+}
+
+// webauthnStore — пользователи и их ключи для церемоний WebAuthn.
+type webauthnStore interface {
+	GetUser(ctx context.Context, userID string) (*postgres.User, error)
+	ListWebAuthnCredentials(ctx context.Context, userID string) ([]postgres.WebAuthnCredential, error)
+	SaveWebAuthnCredential(ctx context.Context, cred *postgres.WebAuthnCredential) error
+	UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, signCount int64, credential string) error
+}
+
+// accountStore — статус аккаунта; всё, что меняется вместе с ним, меняется в InAccountTx.
+type accountStore interface {
+	GetUser(ctx context.Context, userID string) (*postgres.User, error)
+	InvalidateStatus(ctx context.Context, userID string) error
+	InAccountTx(ctx context.Context, fn func(tx accountTx) error) error
+}
+
+type accountTx interface {
+	outboxWriter
+	SetUserStatus(ctx context.Context, userID string, from []string, to, reason string) (string, error)
+	SoftDeleteUser(ctx context.Context, userID string, purgeAfter time.Time) error
+	ListSessions(ctx context.Context, userID string) ([]postgres.RefreshToken, error)
+	DeleteUserSessions(ctx context.Context, userID string) error
+	DeleteUserTrustedDevices(ctx context.Context, userID string) error
+}
+
+type outboxWriter interface {
+	AddOutboxEvent(ctx context.Context, event *postgres.OutboxEvent) error
+}
+
+type auditRecorder interface {
+	Record(ctx context.Context, e audit.Event) error
+	RecordAsync(ctx context.Context, e audit.Event) error
+	Query(ctx context.Context, f audit.Filter) (*audit.Page, error)
+}
+
 type AuthService struct {
-	storage *AuthStorage
-	otpTTL  time.Duration
-	jwt     *JWTManager
+	storage       *AuthStorage
+	logins        loginStore
+	keys          webauthnStore
+	accounts      accountStore
+	otpTTL        time.Duration
+	otpLogin      bool
+	deletionGrace time.Duration
+	jwt           *JWTManager
+	sender        notify.Sender
+	ids           *identifier.Normalizer
+	policy        *password.Policy
+	hasher        *password.Pool
+	lockout       *Lockout
+	auditLog      auditRecorder
+
+	box             *secretbox.Box
+	mfaIssuer       string
+	mfaChallengeTTL time.Duration
+	deviceSecret    string
+	deviceTTL       time.Duration
+
+	webauthn    *webauthn.WebAuthn
+	webauthnTTL time.Duration
+
+	magic  config.MagicLinkConfig
+	alerts config.LoginAlertConfig
+
+	statusCacheTTL time.Duration
+
+	dummyMu   sync.Mutex
+	dummyHash string
 }
 
-func NewAuthService(storage *AuthStorage, otpTTL time.Duration, jwtManager *JWTManager) *AuthService {
-	return &AuthService{storage: storage, otpTTL: otpTTL, jwt: jwtManager}
+const pendingRegistration = "registration"
+
+var ErrInvalidCredentials = errors.New("invalid login or password")
+
+type Options struct {
+	OTPTTL          time.Duration
+	OTPLogin        bool
+	DeletionGrace   time.Duration
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	DeviceSecret    string
+	DeviceTTL       time.Duration
+	WebAuthnTTL     time.Duration
+	MagicLink       config.MagicLinkConfig
+	LoginAlerts     config.LoginAlertConfig
+	StatusCacheTTL  time.Duration
+}
+
+func NewAuthService(
+	storage *AuthStorage,
+	jwtManager *JWTManager,
+	sender notify.Sender,
+	ids *identifier.Normalizer,
+	policy *password.Policy,
+	hasher *password.Pool,
+	lockout *Lockout,
+	box *secretbox.Box,
+	wa *webauthn.WebAuthn,
+	auditLog *audit.Log,
+	opts Options,
+) *AuthService {
+	return &AuthService{
+		storage:       storage,
+		logins:        storage,
+		keys:          storage,
+		accounts:      storage,
+		otpTTL:        opts.OTPTTL,
+		otpLogin:      opts.OTPLogin,
+		deletionGrace: opts.DeletionGrace,
+		jwt:           jwtManager,
+		sender:        sender,
+		ids:           ids,
+		policy:        policy,
+		hasher:        hasher,
+		lockout:       lockout,
+		auditLog:      auditLog,
+
+		box:             box,
+		mfaIssuer:       opts.MFAIssuer,
+		mfaChallengeTTL: opts.MFAChallengeTTL,
+		deviceSecret:    opts.DeviceSecret,
+		deviceTTL:       opts.DeviceTTL,
+
+		webauthn:    wa,
+		webauthnTTL: opts.WebAuthnTTL,
+
+		magic:  opts.MagicLink,
+		alerts: opts.LoginAlerts,
+
+		statusCacheTTL: opts.StatusCacheTTL,
+	}
 }
 
 func (s *AuthService) StartRegistration(ctx context.Context, firstName, lastName, login, password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := validateStruct(RegisterRequest{
+		FirstName: firstName,
+		LastName:  lastName,
+		Login:     login,
+		Password:  password,
+	}); err != nil {
+		return "", err
+	}
+
+	id, err := s.ids.Normalize(login)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.policy.Check(password, firstName, lastName, id.Value); err != nil {
+		return "", err
+	}
+
+	hash, err := s.hasher.Hash(ctx, password)
 	if err != nil {
 		return "", err
 	}
 
-	//otp := generationOTP()
-	otp := "123456"
+	// занятый логин обрабатывается так же, как свободный: ответ и время не выдают,
+	// что адрес уже зарегистрирован; владельцу уходит уведомление вместо кода
+	_, lookupErr := s.logins.GetUserByLogin(ctx, id.Value)
+	existing := lookupErr == nil
 
-	token, err := s.storage.SaveRegistration(ctx, RegistrationData{
+	data := RegistrationData{
+		otpState:     otpState{OTP: generationOTP()},
 		FirstName:    firstName,
 		LastName:     lastName,
-		Login:        login,
-		PasswordHash: string(hash),
-		OTP:          otp,
-	}, s.otpTTL)
+		Login:        id.Value,
+		PasswordHash: hash,
+		Existing:     existing,
+	}
+
+	token, err := s.storage.SaveRegistration(ctx, data, s.otpTTL)
 	if err != nil {
 		return "", err
 	}
 
+	if existing {
+		s.sendAsync(ctx, id.Value,
+			"Registration attempt",
+			"Someone tried to register a new account with this address. You already have an account: sign in or reset your password.",
+		)
+	} else {
+		s.sendAsync(ctx, id.Value, "Confirmation code", "Your confirmation code: "+data.OTP)
+	}
+
 	return token, nil
 }
 
@@ -49,11 +220,26 @@ func (s *AuthService) ConfirmRegistration(ctx context.Context, token, code strin
 		return "", "", err
 	}
 
-	if code != data.OTP {
-		return "", "", errors.New("invalid confirmation code")
+	if err := s.checkCode(ctx, pendingRegistration, token, code, data); err != nil {
+		return "", "", err
+	}
+
+	if data.Existing {
+		return "", "", ErrInvalidCode
 	}
 
-	userId, err := s.storage.CreateUser(ctx, *data)
+	var userId string
+	err = s.storage.InTx(ctx, func(tx *AuthStorage) error {
+		id, err := tx.CreateUser(ctx, *data)
+		if err != nil {
+			return err
+		}
+		if err := tx.AssignRole(ctx, id, RoleUser); err != nil {
+			return err
+		}
+		userId = id
+		return s.publish(ctx, tx, events.UserRegistered, events.UserRegisteredData{UserID: id})
+	})
 	if err != nil {
 		return "", "", err
 	}
@@ -62,19 +248,15 @@ func (s *AuthService) ConfirmRegistration(ctx context.Context, token, code strin
 		return "", "", err
 	}
 
-	accessToken, err := s.jwt.GenerateAccessToken(userId)
+	access, refresh, err := s.issueTokens(ctx, userId, newAuthContext(AMRPassword))
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshToken := uuid.NewString()
-	expiresAt := time.Now().Add(30 * 24 * time.Hour)
+	s.recordEvent(ctx, userId, EventRegistration, AMRPassword, "")
+	s.recordAudit(ctx, audit.Event{Actor: userId, Subject: userId, Action: audit.ActionRegistered})
 
-	if err := s.storage.SaveRefreshToken(ctx, userId, refreshToken, expiresAt); err != nil {
-		return "", "", err
-	}
-
-	return accessToken, refreshToken, nil
+	return access, refresh, nil
 }
 
 func (s *AuthService) Refresh(ctx context.Context, oldRefreshToken string) (string, string, error) {
@@ -84,65 +266,291 @@ func (s *AuthService) Refresh(ctx context.Context, oldRefreshToken string) (stri
 	}
 
 	if time.Now().After(tokenData.ExpiresAt) {
+		s.recordEvent(ctx, tokenData.UserID, EventRefresh, "", "expired")
 		return "", "", errors.New("refresh token expired")
 	}
 
+	// отключённый или заблокированный аккаунт теряет и эту сессию
+	if err := s.checkActive(ctx, tokenData.UserID, EventRefresh, ""); err != nil {
+		_ = s.storage.DeleteRefreshToken(ctx, oldRefreshToken)
+		return "", "", err
+	}
+
 	if err := s.storage.DeleteRefreshToken(ctx, oldRefreshToken); err != nil {
 		return "", "", err
 	}
 
 	newRefreshToken := uuid.NewString()
 	expiresAt := time.Now().Add(30 * 24 * time.Hour)
-	if err := s.storage.SaveRefreshToken(ctx, tokenData.UserID, newRefreshToken, expiresAt); err != nil {
+	// обновление не считается повторной аутентификацией: auth_time и amr переносятся без изменений
+	auth := AuthContext{Time: tokenData.AuthTime, Methods: tokenData.AMR}
+
+	if err := s.storage.SaveRefreshToken(ctx, tokenData.ID, tokenData.UserID, newRefreshToken, expiresAt, auth); err != nil {
 		return "", "", err
 	}
 
-	accessToken, err := s.jwt.GenerateAccessToken(tokenData.UserID)
+	// роли перечитываются при каждом обновлении, чтобы выданные и отозванные роли доходили до токена
+	access, err := s.userAccess(ctx, tokenData.UserID)
 	if err != nil {
 		return "", "", err
 	}
 
+	accessToken, err := s.jwt.GenerateAccessToken(tokenData.UserID, auth, access)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.recordEvent(ctx, tokenData.UserID, EventRefresh, "", "")
+	s.recordAudit(ctx, audit.Event{
+		Actor:    tokenData.UserID,
+		Subject:  tokenData.UserID,
+		Action:   audit.ActionTokenRotated,
+		Metadata: map[string]any{"session_id": tokenData.ID},
+	})
+
 	return accessToken, newRefreshToken, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, login, password string) (string, string, error) {
-	user, err := s.storage.bd.Postgres.GetUserByLogin(ctx, login)
+func (s *AuthService) Login(ctx context.Context, login, password string) (*LoginResult, error) {
+	if err := validateStruct(LoginRequest{Login: login, Password: password}); err != nil {
+		return nil, err
+	}
+
+	var user *postgres.UserInfo
+	normalized := login
+	if id, err := s.ids.Normalize(login); err == nil {
+		normalized = id.Value
+		user, _ = s.logins.GetUserByLogin(ctx, id.Value)
+	}
+
+	ip := requestctx.From(ctx).IP
+	attemptKey := UserKey("", normalized)
+	if user != nil {
+		attemptKey = UserKey(user.ID, "")
+	}
+
+	if err := s.lockout.Check(ctx, attemptKey, ip); err != nil {
+		var throttled *ThrottledError
+		if user != nil && errors.As(err, &throttled) {
+			reason := "throttled"
+			if throttled.Locked {
+				reason = "locked"
+			}
+			s.recordEvent(ctx, user.ID, EventLogin, AMRPassword, reason)
+			s.auditLoginFailed(ctx, user.ID, AMRPassword, reason)
+		}
+		return nil, err
+	}
+
+	// для неизвестного логина всё равно считаем хеш, иначе по времени ответа
+	// видно, какие адреса зарегистрированы
+	if user == nil {
+		if err := s.dummyVerify(ctx, password); isOverloaded(err) {
+			return nil, err
+		}
+		_, _ = s.lockout.Fail(ctx, attemptKey, ip)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.verifyPassword(ctx, user.ID, password, user.PasswordHash); err != nil {
+		if isOverloaded(err) {
+			return nil, err
+		}
+		s.recordEvent(ctx, user.ID, EventLogin, AMRPassword, "invalid_password")
+		s.auditLoginFailed(ctx, user.ID, AMRPassword, "invalid_password")
+		if locked, _ := s.lockout.Fail(ctx, attemptKey, ip); locked {
+			s.notifyLocked(ctx, user)
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	result, err := s.completeLogin(ctx, user.ID, AMRPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// при включённой 2FA счётчик сбрасывает только VerifyMFA: иначе каждый верный пароль
+	// обнулял бы неудачные попытки кода
+	if result.MFAToken == "" {
+		_ = s.lockout.Reset(ctx, attemptKey)
+	}
+
+	return result, nil
+}
+
+// verifyPassword проверяет пароль и, если хеш устарел (bcrypt или старые параметры argon2id),
+// сохраняет новый хеш с текущими параметрами.
+func (s *AuthService) verifyPassword(ctx context.Context, userID, password, hash string) error {
+	match, rehash, err := s.hasher.Verify(ctx, password, hash)
 	if err != nil {
-		return "", "", errors.New("invalid login or password")
+		return err
+	}
+	if !match {
+		return ErrInvalidPassword
 	}
 
-	if err := bcrypt.CompareHashAndPassword(
-		[]byte(user.PasswordHash),
-		[]byte(password),
-	); err != nil {
-		return "", "", errors.New("invalid login or password")
+	if rehash {
+		if newHash, err := s.hasher.Hash(ctx, password); err == nil {
+			_ = s.storage.UpdatePassword(ctx, userID, newHash)
+		}
 	}
 
-	accessToken, err := s.jwt.GenerateAccessToken(user.ID)
+	return nil
+}
+
+// dummyVerify тратит на проверку столько же, сколько проверка настоящего argon2id-хеша.
+func (s *AuthService) dummyVerify(ctx context.Context, password string) error {
+	hash, err := s.dummyPasswordHash(ctx)
 	if err != nil {
-		return "", "", err
+		return err
+	}
+
+	_, _, err = s.hasher.Verify(ctx, password, hash)
+	return err
+}
+
+// dummyPasswordHash считается один раз с текущими параметрами хешера.
+func (s *AuthService) dummyPasswordHash(ctx context.Context) (string, error) {
+	s.dummyMu.Lock()
+	defer s.dummyMu.Unlock()
+
+	if s.dummyHash == "" {
+		hash, err := s.hasher.Hash(ctx, uuid.NewString())
+		if err != nil {
+			return "", err
+		}
+		s.dummyHash = hash
+	}
+
+	return s.dummyHash, nil
+}
+
+// isOverloaded — хеширование не выполнено из-за нехватки мощности или истёкшего дедлайна запроса,
+// а не из-за неверного пароля.
+func isOverloaded(err error) bool {
+	var busy *password.BusyError
+	return errors.As(err, &busy) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, userID string, auth AuthContext) (string, string, error) {
+	_, accessToken, refreshToken, err := s.issueSession(ctx, userID, auth)
+	return accessToken, refreshToken, err
+}
+
+// issueSession открывает новую сессию и возвращает её идентификатор вместе с токенами.
+func (s *AuthService) issueSession(ctx context.Context, userID string, auth AuthContext) (string, string, string, error) {
+	access, err := s.userAccess(ctx, userID)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	accessToken, err := s.jwt.GenerateAccessToken(userID, auth, access)
+	if err != nil {
+		return "", "", "", err
 	}
 
+	sessionID := uuid.NewString()
 	refreshToken := uuid.NewString()
 	expiresAt := time.Now().Add(30 * 24 * time.Hour)
 
-	if err := s.storage.SaveRefreshToken(ctx, user.ID, refreshToken, expiresAt); err != nil {
-		return "", "", err
+	if err := s.storage.SaveRefreshToken(ctx, sessionID, userID, refreshToken, expiresAt, auth); err != nil {
+		return "", "", "", err
 	}
 
-	return accessToken, refreshToken, nil
+	return sessionID, accessToken, refreshToken, nil
+}
+
+func newAuthContext(methods ...string) AuthContext {
+	return AuthContext{Time: time.Now(), Methods: methods}
+}
+
+func (s *AuthService) notifyLocked(ctx context.Context, user *postgres.UserInfo) {
+	for _, to := range []sql.NullString{user.Email, user.Phone} {
+		if to.Valid {
+			s.sendAsync(ctx, to.String,
+				"Your account was temporarily locked",
+				"We locked sign-in to your account after several failed attempts. It will unlock automatically; if these attempts weren't yours, reset your password.",
+			)
+		}
+	}
 }
 
-func generationOTP() string {
-	const digits = "0123456789"
-	otp := make([]byte, 6)
-	for i := range otp {
-		otp[i] = digits[randomInt(len(digits))]
+// UnlockUser снимает и временную блокировку после неудачных входов, и статус locked.
+func (s *AuthService) UnlockUser(ctx context.Context, actor, userID string) error {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.lockout.Unlock(ctx, userID); err != nil {
+		return err
+	}
+
+	if user.Status == postgres.UserStatusLocked {
+		return s.changeStatus(ctx, actor, userID,
+			[]string{postgres.UserStatusLocked},
+			postgres.UserStatusActive, "", audit.ActionUserUnlocked)
 	}
 
-	return string(otp)
+	s.recordAudit(ctx, audit.Event{Actor: actor, Subject: userID, Action: audit.ActionUserUnlocked})
+
+	return nil
 }
 
-func randomInt(max int) int {
-	return int(time.Now().UnixNano() % int64(max))
+// Logout завершает сессию, к которой относится refresh-токен.
+func (s *AuthService) Logout(ctx context.Context, userID, refreshToken string) error {
+	// сессия завершается через revokeSession, чтобы подписчики получили session.revoked;
+	// чужой или уже удалённый токен не трогаем
+	if refreshToken != "" {
+		if session, err := s.storage.GetRefreshToken(ctx, refreshToken); err == nil && session.UserID == userID {
+			if err := s.revokeSession(ctx, userID, session.ID, "logout"); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.recordAudit(ctx, audit.Event{Actor: userID, Subject: userID, Action: audit.ActionLogout})
+
+	return nil
+}
+
+// publish кладёт событие в outbox в транзакции tx: подписчики узнают о нём, только если
+// изменение зафиксировано, и обязательно узнают, если зафиксировано.
+func (s *AuthService) publish(ctx context.Context, tx outboxWriter, eventType string, data any) error {
+	event, err := events.New(eventType, data)
+	if err != nil {
+		return err
+	}
+	return tx.AddOutboxEvent(ctx, event)
+}
+
+// recordAudit пишет событие в журнал аудита; сбой журнала не отменяет уже выполненное действие,
+// но попадает в лог с уровнем error.
+func (s *AuthService) recordAudit(ctx context.Context, e audit.Event) {
+	if err := s.auditLog.RecordAsync(ctx, e); err != nil {
+		s.storage.bd.Log.Error("record audit event", slog.String("action", e.Action), slog.String("error", err.Error()))
+	}
+}
+
+func (s *AuthService) auditLoginFailed(ctx context.Context, userID, method, reason string) {
+	s.recordAudit(ctx, audit.Event{
+		Actor:    userID,
+		Subject:  userID,
+		Action:   audit.ActionLoginFailed,
+		Metadata: map[string]any{"method": method, "reason": reason},
+	})
+}
+
+// sendAsync отправляет сообщение в фоне, чтобы время ответа не зависело от того,
+// было ли вообще что отправлять.
+func (s *AuthService) sendAsync(ctx context.Context, to, subject, body string) {
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		if err := s.sender.Send(ctx, to, subject, body); err != nil {
+			s.storage.bd.Log.Error("send notification", slog.String("subject", subject), slog.String("error", err.Error()))
+		}
+	}()
 }
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/events"
+	"context"
+	"time"
+)
+
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
+// ListSessions отдаёт активные сессии (refresh-токены) без самих токенов.
+func (s *AuthService) ListSessions(ctx context.Context, userID, currentRefresh string) ([]SessionInfo, error) {
+	tokens, err := s.storage.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := make([]SessionInfo, 0, len(tokens))
+	for _, t := range tokens {
+		if now.After(t.ExpiresAt) {
+			continue
+		}
+		result = append(result, SessionInfo{
+			ID:        t.ID,
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: t.ExpiresAt,
+			Current:   currentRefresh != "" && t.Token == currentRefresh,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, userID, id string) error {
+	return s.revokeSession(ctx, userID, id, "user")
+}
+
+// revokeSession завершает сессию и публикует session.revoked; reason — кто и откуда её завершил.
+func (s *AuthService) revokeSession(ctx context.Context, userID, id, reason string) error {
+	err := s.storage.InTx(ctx, func(tx *AuthStorage) error {
+		if err := tx.DeleteSession(ctx, userID, id); err != nil {
+			return err
+		}
+		return s.publish(ctx, tx, events.SessionRevoked, events.SessionRevokedData{
+			UserID:    userID,
+			SessionID: id,
+			Reason:    reason,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:    userID,
+		Subject:  userID,
+		Action:   audit.ActionSessionRevoked,
+		Metadata: map[string]any{"session_id": id, "reason": reason},
+	})
+
+	return nil
+}
+
+// revokeAllSessions завершает все сессии и доверенные устройства в транзакции tx
+// и публикует session.revoked для каждой сессии.
+func (s *AuthService) revokeAllSessions(ctx context.Context, tx accountTx, userID, reason string) error {
+	sessions, err := tx.ListSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.DeleteUserTrustedDevices(ctx, userID); err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		err := s.publish(ctx, tx, events.SessionRevoked, events.SessionRevokedData{
+			UserID:    userID,
+			SessionID: session.ID,
+			Reason:    reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
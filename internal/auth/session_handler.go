@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"ahub/storage/postgres"
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	refreshToken, _ := c.Cookie("refresh_token")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	sessions, err := h.service.ListSessions(ctx, c.GetString("user_id"), refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RevokeSession(ctx, c.GetString("user_id"), c.Param("id")); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+func (h *AuthHandler) ListTrustedDevices(c *gin.Context) {
+	deviceToken, _ := c.Cookie(trustedDeviceCookie)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	devices, err := h.service.ListTrustedDevices(ctx, c.GetString("user_id"), deviceToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trusted_devices": devices})
+}
+
+func (h *AuthHandler) RevokeTrustedDevice(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RevokeTrustedDevice(ctx, c.GetString("user_id"), c.Param("id")); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "trusted device revoked"})
+}
+
+func (h *AuthHandler) RevokeAllTrustedDevices(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RevokeAllTrustedDevices(ctx, c.GetString("user_id")); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.SetCookie(trustedDeviceCookie, "", -1, "/", "", true, true)
+
+	c.JSON(http.StatusOK, gin.H{"message": "all trusted devices revoked"})
+}
+
+func respondSessionError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, postgres.ErrSessionNotFound) || errors.Is(err, postgres.ErrTrustedDeviceNotFound) {
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// notMePage: GET только показывает кнопку, сессия завершается отправкой формы (POST), чтобы
+// ссылку не «нажали» почтовые сканеры и предзагрузка в мессенджерах.
+var notMePage = template.Must(template.New("not-me").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Sign-in alert</title>
+</head>
+<body>
+<p>{{.Message}}</p>
+{{if .Confirm}}<form method="post"><button type="submit">End that session</button></form>{{end}}
+</body>
+</html>
+`))
+
+type notMeView struct {
+	Message string
+	Confirm bool
+}
+
+// ConfirmRevokeFromAlert открывает ссылку «это был не я» из уведомления о новом входе.
+func (h *AuthHandler) ConfirmRevokeFromAlert(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.CheckRevokeLink(ctx, c.Param("token")); err != nil {
+		respondNotMe(c, notMeStatus(err), notMeView{Message: notMeMessage(err)})
+		return
+	}
+
+	respondNotMe(c, http.StatusOK, notMeView{
+		Message: "If you did not just sign in from a new device, end that session and then change your password.",
+		Confirm: true,
+	})
+}
+
+// RevokeFromAlert завершает сессию после подтверждения на странице ConfirmRevokeFromAlert.
+func (h *AuthHandler) RevokeFromAlert(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RevokeFromAlert(ctx, c.Param("token")); err != nil {
+		respondNotMe(c, notMeStatus(err), notMeView{Message: notMeMessage(err)})
+		return
+	}
+
+	respondNotMe(c, http.StatusOK, notMeView{
+		Message: "The session has been ended. Change your password to secure the account.",
+	})
+}
+
+func notMeStatus(err error) int {
+	if errors.Is(err, ErrRevokeLinkInvalid) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func notMeMessage(err error) string {
+	if errors.Is(err, ErrRevokeLinkInvalid) {
+		return "This link is invalid or has already been used."
+	}
+	return "Something went wrong, please try again later."
+}
+
+func respondNotMe(c *gin.Context, status int, view notMeView) {
+	var buf bytes.Buffer
+	if err := notMePage.Execute(&buf, view); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	// токен в адресе страницы: не кэшировать и не передавать в Referer
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
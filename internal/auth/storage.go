@@ -2,21 +2,29 @@ package auth
 
 import (
 	"ahub/storage"
+	"ahub/storage/postgres"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
+var ErrPendingNotFound = errors.New("confirmation token is invalid or expired")
+
 type AuthStorage struct {
 	bd *storage.Storage
 }
 
 type RefreshTokenData struct {
+	ID        string
 	UserID    string
 	ExpiresAt time.Time
+	AuthTime  time.Time
+	AMR       []string
 }
 
 func NewStorage(s *storage.Storage) *AuthStorage {
@@ -24,11 +32,13 @@ func NewStorage(s *storage.Storage) *AuthStorage {
 }
 
 type RegistrationData struct {
+	otpState
 	FirstName    string `json:"first_name"`
 	LastName     string `json:"last_name"`
 	Login        string `json:"login"`
 	PasswordHash string `json:"password_hash"`
-	OTP          string `json:"otp"`
+	// Existing — логин уже зарегистрирован: код не отправлялся, подтверждение всегда отклоняется.
+	Existing bool `json:"existing,omitempty"`
 }
 
 func (s *AuthStorage) SaveRegistration(ctx context.Context, data RegistrationData, ttl time.Duration) (string, error) {
@@ -57,12 +67,6 @@ func (s *AuthStorage) SaveRegistration(ctx context.Context, data RegistrationDat
 		return "", err
 	}
 
-	val, err := s.bd.Redis.Client.Get(ctx, key).Result()
-	if err != nil {
-		return "", err
-	}
-	fmt.Println("REDIS VALUE:", val)
-
 	return token, nil
 }
 
@@ -93,16 +97,18 @@ func (s *AuthStorage) CreateUser(ctx context.Context, data RegistrationData) (st
 
 func (s *AuthStorage) SaveRefreshToken(
 	ctx context.Context,
+	sessionID string,
 	userID string,
 	refreshToken string,
 	expiresAt time.Time,
+	auth AuthContext,
 ) error {
 
 	if s == nil || s.bd == nil || s.bd.Postgres == nil {
 		return fmt.Errorf("postgres storage is nil")
 	}
 
-	return s.bd.Postgres.SaveRefreshToken(ctx, userID, refreshToken, expiresAt)
+	return s.bd.Postgres.SaveRefreshToken(ctx, sessionID, userID, refreshToken, expiresAt, auth.Time, auth.Methods)
 }
 
 func (s *AuthStorage) GetRefreshToken(ctx context.Context, token string) (*RefreshTokenData, error) {
@@ -125,3 +131,256 @@ func (s *AuthStorage) DeleteRefreshToken(ctx context.Context, token string) erro
 
 	return s.bd.Postgres.DeleteRefreshToken(ctx, token)
 }
+
+// SavePending сохраняет отложенное действие (по аналогии с регистрацией) и возвращает его токен.
+func (s *AuthStorage) SavePending(ctx context.Context, kind string, data any, ttl time.Duration) (string, error) {
+	if s == nil || s.bd == nil || s.bd.Redis == nil || s.bd.Redis.Client == nil {
+		return "", fmt.Errorf("redis storage is nil")
+	}
+
+	token := uuid.NewString()
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.bd.Redis.Client.Set(ctx, pendingKey(kind, token), payload, ttl).Err(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *AuthStorage) GetPending(ctx context.Context, kind, token string, dst any) error {
+	val, err := s.bd.Redis.Client.Get(ctx, pendingKey(kind, token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrPendingNotFound
+		}
+		return err
+	}
+
+	return json.Unmarshal([]byte(val), dst)
+}
+
+// UpdatePending перезаписывает данные, не продлевая срок жизни ключа.
+func (s *AuthStorage) UpdatePending(ctx context.Context, kind, token string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return s.bd.Redis.Client.SetArgs(ctx, pendingKey(kind, token), payload, redis.SetArgs{
+		Mode:    "XX",
+		KeepTTL: true,
+	}).Err()
+}
+
+// TakePending читает и удаляет данные одной операцией: одноразовый токен нельзя
+// погасить дважды даже при параллельных запросах.
+func (s *AuthStorage) TakePending(ctx context.Context, kind, token string, dst any) error {
+	val, err := s.bd.Redis.Client.GetDel(ctx, pendingKey(kind, token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrPendingNotFound
+		}
+		return err
+	}
+
+	return json.Unmarshal([]byte(val), dst)
+}
+
+func (s *AuthStorage) DeletePending(ctx context.Context, kind, token string) error {
+	return s.bd.Redis.Client.Del(ctx, pendingKey(kind, token)).Err()
+}
+
+func pendingKey(kind, token string) string {
+	return fmt.Sprintf("%s:%s", kind, token)
+}
+
+func (s *AuthStorage) GetUser(ctx context.Context, userID string) (*postgres.User, error) {
+	return s.bd.Postgres.GetUserByID(ctx, userID)
+}
+
+func (s *AuthStorage) UpdateEmail(ctx context.Context, userID, email string) error {
+	return s.bd.Postgres.UpdateUserEmail(ctx, userID, email)
+}
+
+func (s *AuthStorage) UpdatePhone(ctx context.Context, userID, phone string) error {
+	return s.bd.Postgres.UpdateUserPhone(ctx, userID, phone)
+}
+
+func (s *AuthStorage) SoftDeleteUser(ctx context.Context, userID string, purgeAfter time.Time) error {
+	return s.bd.Postgres.SoftDeleteUser(ctx, userID, purgeAfter)
+}
+
+func (s *AuthStorage) PurgeDeletedUsers(ctx context.Context, now time.Time) (int64, error) {
+	return s.bd.Postgres.PurgeDeletedUsers(ctx, now)
+}
+
+func (s *AuthStorage) ListSessions(ctx context.Context, userID string) ([]postgres.RefreshToken, error) {
+	return s.bd.Postgres.ListRefreshTokens(ctx, userID)
+}
+
+func (s *AuthStorage) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	return s.bd.Postgres.UpdateUserPassword(ctx, userID, passwordHash)
+}
+
+func (s *AuthStorage) DeleteUserSessions(ctx context.Context, userID string) error {
+	return s.bd.Postgres.DeleteUserRefreshTokens(ctx, userID)
+}
+
+func (s *AuthStorage) SaveTOTPSecret(ctx context.Context, userID, secretEncrypted string) error {
+	return s.bd.Postgres.SaveTOTPSecret(ctx, userID, secretEncrypted)
+}
+
+func (s *AuthStorage) GetTOTP(ctx context.Context, userID string) (*postgres.UserTOTP, error) {
+	return s.bd.Postgres.GetTOTP(ctx, userID)
+}
+
+func (s *AuthStorage) EnableTOTP(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	return s.bd.Postgres.EnableTOTP(ctx, userID, step, codeHashes)
+}
+
+func (s *AuthStorage) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	return s.bd.Postgres.UseTOTPStep(ctx, userID, step)
+}
+
+func (s *AuthStorage) DeleteTOTP(ctx context.Context, userID string) error {
+	return s.bd.Postgres.DeleteTOTP(ctx, userID)
+}
+
+func (s *AuthStorage) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	return s.bd.Postgres.ReplaceRecoveryCodes(ctx, userID, codeHashes)
+}
+
+func (s *AuthStorage) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	return s.bd.Postgres.UseRecoveryCode(ctx, userID, codeHash)
+}
+
+func (s *AuthStorage) SaveWebAuthnCredential(ctx context.Context, cred *postgres.WebAuthnCredential) error {
+	return s.bd.Postgres.SaveWebAuthnCredential(ctx, cred)
+}
+
+func (s *AuthStorage) ListWebAuthnCredentials(ctx context.Context, userID string) ([]postgres.WebAuthnCredential, error) {
+	return s.bd.Postgres.ListWebAuthnCredentials(ctx, userID)
+}
+
+func (s *AuthStorage) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (*postgres.WebAuthnCredential, error) {
+	return s.bd.Postgres.GetWebAuthnCredential(ctx, credentialID)
+}
+
+func (s *AuthStorage) UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, signCount int64, credential string) error {
+	return s.bd.Postgres.UpdateWebAuthnCredential(ctx, credentialID, signCount, credential)
+}
+
+func (s *AuthStorage) DeleteWebAuthnCredential(ctx context.Context, userID, id string) error {
+	return s.bd.Postgres.DeleteWebAuthnCredential(ctx, userID, id)
+}
+
+func (s *AuthStorage) DeleteSession(ctx context.Context, userID, id string) error {
+	return s.bd.Postgres.DeleteUserRefreshTokenByID(ctx, userID, id)
+}
+
+func (s *AuthStorage) CreateTrustedDevice(ctx context.Context, device *postgres.TrustedDevice) error {
+	return s.bd.Postgres.CreateTrustedDevice(ctx, device)
+}
+
+func (s *AuthStorage) GetTrustedDevice(ctx context.Context, id string) (*postgres.TrustedDevice, error) {
+	return s.bd.Postgres.GetTrustedDevice(ctx, id)
+}
+
+func (s *AuthStorage) TouchTrustedDevice(ctx context.Context, id, ip string) error {
+	return s.bd.Postgres.TouchTrustedDevice(ctx, id, ip)
+}
+
+func (s *AuthStorage) ListTrustedDevices(ctx context.Context, userID string) ([]postgres.TrustedDevice, error) {
+	return s.bd.Postgres.ListTrustedDevices(ctx, userID)
+}
+
+func (s *AuthStorage) DeleteTrustedDevice(ctx context.Context, userID, id string) error {
+	return s.bd.Postgres.DeleteTrustedDevice(ctx, userID, id)
+}
+
+func (s *AuthStorage) DeleteUserTrustedDevices(ctx context.Context, userID string) error {
+	return s.bd.Postgres.DeleteUserTrustedDevices(ctx, userID)
+}
+
+func (s *AuthStorage) LoginDeviceSeen(ctx context.Context, userID, deviceKey string) (bool, bool, error) {
+	return s.bd.Postgres.LoginDeviceSeen(ctx, userID, deviceKey)
+}
+
+func (s *AuthStorage) GetUserByLogin(ctx context.Context, login string) (*postgres.UserInfo, error) {
+	return s.bd.Postgres.GetUserByLogin(ctx, login)
+}
+
+func (s *AuthStorage) CreateLoginEvent(ctx context.Context, event *postgres.LoginEvent) error {
+	return s.bd.Postgres.CreateLoginEvent(ctx, event)
+}
+
+func (s *AuthStorage) ListLoginEvents(ctx context.Context, userID string, after *postgres.LoginEventCursor, limit int) ([]postgres.LoginEvent, error) {
+	return s.bd.Postgres.ListLoginEvents(ctx, userID, after, limit)
+}
+
+func (s *AuthStorage) PurgeLoginEvents(ctx context.Context, before time.Time) (int64, error) {
+	return s.bd.Postgres.PurgeLoginEvents(ctx, before)
+}
+
+// InTx выполняет fn в транзакции Postgres; Redis в транзакции не участвует.
+func (s *AuthStorage) InTx(ctx context.Context, fn func(tx *AuthStorage) error) error {
+	return s.bd.Postgres.InTx(ctx, func(pg *postgres.Storage) error {
+		bd := *s.bd
+		bd.Postgres = pg
+		return fn(&AuthStorage{bd: &bd})
+	})
+}
+
+// InAccountTx — InTx для accountStore.
+func (s *AuthStorage) InAccountTx(ctx context.Context, fn func(tx accountTx) error) error {
+	return s.InTx(ctx, func(tx *AuthStorage) error { return fn(tx) })
+}
+
+func (s *AuthStorage) AddOutboxEvent(ctx context.Context, event *postgres.OutboxEvent) error {
+	return s.bd.Postgres.AddOutboxEvent(ctx, event)
+}
+
+func (s *AuthStorage) GetUserAccess(ctx context.Context, userID string) ([]string, []string, error) {
+	return s.bd.Postgres.GetUserAccess(ctx, userID)
+}
+
+func (s *AuthStorage) AssignRole(ctx context.Context, userID, role string) error {
+	return s.bd.Postgres.AssignRole(ctx, userID, role)
+}
+
+func (s *AuthStorage) RevokeRole(ctx context.Context, userID, role string) error {
+	return s.bd.Postgres.RevokeRole(ctx, userID, role)
+}
+
+func (s *AuthStorage) ListRoles(ctx context.Context) ([]postgres.Role, map[string][]string, error) {
+	return s.bd.Postgres.ListRoles(ctx)
+}
+
+func (s *AuthStorage) ListUsers(ctx context.Context, f postgres.UserFilter) ([]postgres.User, error) {
+	return s.bd.Postgres.ListUsers(ctx, f)
+}
+
+func (s *AuthStorage) GetCachedStatus(ctx context.Context, userID string) (string, error) {
+	return s.bd.Redis.Client.Get(ctx, statusKey(userID)).Result()
+}
+
+func (s *AuthStorage) CacheStatus(ctx context.Context, userID, status string, ttl time.Duration) error {
+	return s.bd.Redis.Client.Set(ctx, statusKey(userID), status, ttl).Err()
+}
+
+func (s *AuthStorage) InvalidateStatus(ctx context.Context, userID string) error {
+	return s.bd.Redis.Client.Del(ctx, statusKey(userID)).Err()
+}
+
+func statusKey(userID string) string {
+	return fmt.Sprintf("user_status:%s", userID)
+}
+
+func (s *AuthStorage) SetUserStatus(ctx context.Context, userID string, from []string, to, reason string) (string, error) {
+	return s.bd.Postgres.SetUserStatus(ctx, userID, from, to, reason)
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const trustedDeviceCookie = "trusted_device"
+
+type deviceTokenKey struct{}
+
+type TrustedDeviceInfo struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// withDeviceToken передаёт значение cookie доверенного устройства в completeLogin.
+func withDeviceToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, deviceTokenKey{}, token)
+}
+
+func deviceTokenFrom(ctx context.Context) string {
+	token, _ := ctx.Value(deviceTokenKey{}).(string)
+	return token
+}
+
+// rememberDevice заводит доверенное устройство; cookie — "<id>.<подпись>", подпись
+// привязывает её к пользователю, так что чужая cookie не подойдёт даже с верным id.
+func (s *AuthService) rememberDevice(ctx context.Context, userID string) (string, time.Time, error) {
+	if s.deviceSecret == "" {
+		return "", time.Time{}, nil
+	}
+
+	info := requestctx.From(ctx)
+	now := time.Now()
+
+	device := &postgres.TrustedDevice{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		Fingerprint: deviceFingerprint(info.UserAgent),
+		UserAgent:   info.UserAgent,
+		IP:          info.IP,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(s.deviceTTL),
+	}
+
+	if err := s.storage.CreateTrustedDevice(ctx, device); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return device.ID + "." + s.deviceSignature(device.ID, userID), device.ExpiresAt, nil
+}
+
+// isTrustedDevice: устройство действует, если подпись верна, срок не истёк и браузер тот же
+// (после смены User-Agent второй фактор спросят снова).
+func (s *AuthService) isTrustedDevice(ctx context.Context, userID string) bool {
+	id, ok := s.verifyDeviceToken(deviceTokenFrom(ctx), userID)
+	if !ok {
+		return false
+	}
+
+	device, err := s.storage.GetTrustedDevice(ctx, id)
+	if err != nil || device.UserID != userID {
+		return false
+	}
+
+	info := requestctx.From(ctx)
+	if device.Fingerprint != deviceFingerprint(info.UserAgent) {
+		return false
+	}
+
+	_ = s.storage.TouchTrustedDevice(ctx, device.ID, info.IP)
+
+	return true
+}
+
+func (s *AuthService) ListTrustedDevices(ctx context.Context, userID, currentToken string) ([]TrustedDeviceInfo, error) {
+	devices, err := s.storage.ListTrustedDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	currentID, _ := s.verifyDeviceToken(currentToken, userID)
+
+	result := make([]TrustedDeviceInfo, 0, len(devices))
+	for _, d := range devices {
+		result = append(result, TrustedDeviceInfo{
+			ID:         d.ID,
+			UserAgent:  d.UserAgent,
+			IP:         d.IP,
+			CreatedAt:  d.CreatedAt,
+			LastSeenAt: d.LastSeenAt,
+			ExpiresAt:  d.ExpiresAt,
+			Current:    d.ID == currentID,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *AuthService) RevokeTrustedDevice(ctx context.Context, userID, id string) error {
+	return s.storage.DeleteTrustedDevice(ctx, userID, id)
+}
+
+func (s *AuthService) RevokeAllTrustedDevices(ctx context.Context, userID string) error {
+	return s.storage.DeleteUserTrustedDevices(ctx, userID)
+}
+
+func (s *AuthService) verifyDeviceToken(token, userID string) (string, bool) {
+	if s.deviceSecret == "" || token == "" {
+		return "", false
+	}
+
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(s.deviceSignature(id, userID))) {
+		return "", false
+	}
+
+	return id, true
+}
+
+func (s *AuthService) deviceSignature(id, userID string) string {
+	mac := hmac.New(sha256.New, []byte(s.deviceSecret))
+	mac.Write([]byte(trustedDeviceCookie + ":" + id + ":" + userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func deviceFingerprint(userAgent string) string {
+	sum := sha256.Sum256([]byte(userAgent))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"log/slog"
+)
+
+var (
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrAccountLocked   = errors.New("account is locked, contact support")
+)
+
+// statusError — ошибка входа для статуса аккаунта; nil, если входить можно.
+func statusError(status string) error {
+	switch status {
+	case postgres.UserStatusActive:
+		return nil
+	case postgres.UserStatusDisabled:
+		return ErrAccountDisabled
+	case postgres.UserStatusLocked:
+		return ErrAccountLocked
+	default:
+		return postgres.ErrUserNotFound
+	}
+}
+
+// checkActive не даёт войти или обновить токен, если аккаунт не активен; отказ попадает
+// в историю входов и журнал аудита.
+func (s *AuthService) checkActive(ctx context.Context, userID, event, method string) error {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = statusError(user.Status)
+	if errors.Is(err, ErrAccountDisabled) || errors.Is(err, ErrAccountLocked) {
+		reason := "account_" + user.Status
+		s.recordEvent(ctx, userID, event, method, reason)
+		s.auditLoginFailed(ctx, userID, method, reason)
+	}
+
+	return err
+}
+
+// CheckStatus — проверка статуса для AuthMiddleware: access-токен заблокированного пользователя
+// перестаёт действовать не позже чем через statusCacheTTL. Нулевой TTL отключает проверку.
+func (s *AuthService) CheckStatus(ctx context.Context, userID string) error {
+	if s.statusCacheTTL <= 0 {
+		return nil
+	}
+
+	status, err := s.storage.GetCachedStatus(ctx, userID)
+	if err == nil {
+		return statusError(status)
+	}
+
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.CacheStatus(ctx, userID, user.Status, s.statusCacheTTL); err != nil {
+		s.storage.bd.Log.Warn("cache user status", slog.String("error", err.Error()))
+	}
+
+	return statusError(user.Status)
+}
+
+// DisableUser отключает аккаунт и завершает все его сессии.
+func (s *AuthService) DisableUser(ctx context.Context, actor, userID, reason string) error {
+	return s.changeStatus(ctx, actor, userID,
+		[]string{postgres.UserStatusActive, postgres.UserStatusLocked},
+		postgres.UserStatusDisabled, reason, audit.ActionUserDisabled)
+}
+
+func (s *AuthService) EnableUser(ctx context.Context, actor, userID string) error {
+	return s.changeStatus(ctx, actor, userID,
+		[]string{postgres.UserStatusDisabled},
+		postgres.UserStatusActive, "", audit.ActionUserEnabled)
+}
+
+// LockUser блокирует аккаунт по соображениям безопасности; снимает блокировку UnlockUser.
+func (s *AuthService) LockUser(ctx context.Context, actor, userID, reason string) error {
+	return s.changeStatus(ctx, actor, userID,
+		[]string{postgres.UserStatusActive},
+		postgres.UserStatusLocked, reason, audit.ActionUserLocked)
+}
+
+// RestoreUser отменяет запланированное удаление, пока Purger не стёр данные. Удаляются только
+// активные аккаунты (см. scheduleDeletion), поэтому восстановленный снова становится active.
+func (s *AuthService) RestoreUser(ctx context.Context, actor, userID string) error {
+	return s.changeStatus(ctx, actor, userID,
+		[]string{postgres.UserStatusPendingDeletion},
+		postgres.UserStatusActive, "", audit.ActionUserRestored)
+}
+
+// changeStatus переводит аккаунт из одного из статусов from в to. При переходе в неактивный
+// статус сессии и доверенные устройства удаляются в той же транзакции.
+func (s *AuthService) changeStatus(ctx context.Context, actor, userID string, from []string, to, reason, action string) error {
+	var prev string
+
+	err := s.accounts.InAccountTx(ctx, func(tx accountTx) error {
+		var err error
+		prev, err = tx.SetUserStatus(ctx, userID, from, to, reason)
+		if err != nil {
+			return err
+		}
+
+		if to == postgres.UserStatusActive {
+			return nil
+		}
+		return s.revokeAllSessions(ctx, tx, userID, "account_"+to)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.accounts.InvalidateStatus(ctx, userID); err != nil {
+		s.storage.bd.Log.Warn("invalidate user status", slog.String("error", err.Error()))
+	}
+
+	metadata := map[string]any{"from": prev, "to": to}
+	if reason != "" {
+		metadata["reason"] = reason
+	}
+	s.recordAudit(ctx, audit.Event{Actor: actor, Subject: userID, Action: action, Metadata: metadata})
+
+	return nil
+}
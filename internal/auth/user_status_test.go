@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/storage/postgres"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeAccounts хранит пользователей в памяти; транзакция применяется к ним напрямую.
+type fakeAccounts struct {
+	users  map[string]*postgres.User
+	events []*postgres.OutboxEvent
+}
+
+func newFakeAccounts(users ...*postgres.User) *fakeAccounts {
+	f := &fakeAccounts{users: map[string]*postgres.User{}}
+	for _, u := range users {
+		f.users[u.ID] = u
+	}
+	return f
+}
+
+func (f *fakeAccounts) GetUser(ctx context.Context, userID string) (*postgres.User, error) {
+	u, ok := f.users[userID]
+	if !ok {
+		return nil, postgres.ErrUserNotFound
+	}
+	clone := *u
+	return &clone, nil
+}
+
+func (f *fakeAccounts) InvalidateStatus(ctx context.Context, userID string) error { return nil }
+
+func (f *fakeAccounts) InAccountTx(ctx context.Context, fn func(tx accountTx) error) error {
+	return fn(f)
+}
+
+func (f *fakeAccounts) SetUserStatus(ctx context.Context, userID string, from []string, to, reason string) (string, error) {
+	u, ok := f.users[userID]
+	if !ok {
+		return "", postgres.ErrUserNotFound
+	}
+	if !slices.Contains(from, u.Status) {
+		return "", postgres.ErrStatusTransition
+	}
+
+	prev := u.Status
+	u.Status = to
+	u.StatusReason = nil
+	if reason != "" {
+		u.StatusReason = &reason
+	}
+	return prev, nil
+}
+
+func (f *fakeAccounts) SoftDeleteUser(ctx context.Context, userID string, purgeAfter time.Time) error {
+	u, ok := f.users[userID]
+	if !ok {
+		return postgres.ErrUserNotFound
+	}
+	if u.Status != postgres.UserStatusActive {
+		return postgres.ErrStatusTransition
+	}
+	u.Status = postgres.UserStatusPendingDeletion
+	return nil
+}
+
+func (f *fakeAccounts) ListSessions(ctx context.Context, userID string) ([]postgres.RefreshToken, error) {
+	return nil, nil
+}
+
+func (f *fakeAccounts) DeleteUserSessions(ctx context.Context, userID string) error { return nil }
+
+func (f *fakeAccounts) DeleteUserTrustedDevices(ctx context.Context, userID string) error { return nil }
+
+func (f *fakeAccounts) AddOutboxEvent(ctx context.Context, event *postgres.OutboxEvent) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+// actionsAudit запоминает действия, попавшие в журнал.
+type actionsAudit struct {
+	fakeAudit
+	actions []string
+}
+
+func (a *actionsAudit) RecordAsync(ctx context.Context, e audit.Event) error {
+	a.actions = append(a.actions, e.Action)
+	return nil
+}
+
+// Удаление не должно становиться обходным путём для включения отключённого аккаунта:
+// RestoreUser всегда возвращает аккаунт в active.
+func TestDisabledUserCannotBeRestoredToActive(t *testing.T) {
+	accounts := newFakeAccounts(&postgres.User{ID: "u1", Status: postgres.UserStatusActive})
+	auditLog := &actionsAudit{}
+	s := &AuthService{accounts: accounts, auditLog: auditLog}
+	ctx := context.Background()
+
+	if err := s.DisableUser(ctx, "admin", "u1", "abuse"); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+
+	if _, err := s.AdminDeleteUser(ctx, "admin", "u1"); !errors.Is(err, postgres.ErrStatusTransition) {
+		t.Fatalf("delete disabled user: got %v, want ErrStatusTransition", err)
+	}
+
+	if err := s.RestoreUser(ctx, "admin", "u1"); !errors.Is(err, postgres.ErrStatusTransition) {
+		t.Fatalf("restore disabled user: got %v, want ErrStatusTransition", err)
+	}
+
+	u := accounts.users["u1"]
+	if u.Status != postgres.UserStatusDisabled {
+		t.Fatalf("status = %q, want %q", u.Status, postgres.UserStatusDisabled)
+	}
+	if u.StatusReason == nil || *u.StatusReason != "abuse" {
+		t.Fatalf("status reason = %v, want abuse", u.StatusReason)
+	}
+	if len(accounts.events) != 0 {
+		t.Fatalf("published %d events, want none", len(accounts.events))
+	}
+	if want := []string{audit.ActionUserDisabled}; !slices.Equal(auditLog.actions, want) {
+		t.Fatalf("audit actions = %v, want %v", auditLog.actions, want)
+	}
+}
+
+func TestLockedUserCannotBeDeleted(t *testing.T) {
+	accounts := newFakeAccounts(&postgres.User{ID: "u1", Status: postgres.UserStatusActive})
+	s := &AuthService{accounts: accounts, auditLog: &actionsAudit{}}
+	ctx := context.Background()
+
+	if err := s.LockUser(ctx, "admin", "u1", "compromised"); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	if _, err := s.AdminDeleteUser(ctx, "admin", "u1"); !errors.Is(err, postgres.ErrStatusTransition) {
+		t.Fatalf("delete locked user: got %v, want ErrStatusTransition", err)
+	}
+	if got := accounts.users["u1"].Status; got != postgres.UserStatusLocked {
+		t.Fatalf("status = %q, want %q", got, postgres.UserStatusLocked)
+	}
+}
+
+// Активный аккаунт по-прежнему удаляется и восстанавливается в active.
+func TestActiveUserDeleteAndRestore(t *testing.T) {
+	accounts := newFakeAccounts(&postgres.User{ID: "u1", Status: postgres.UserStatusActive})
+	auditLog := &actionsAudit{}
+	s := &AuthService{accounts: accounts, auditLog: auditLog, sender: nopSender{}, deletionGrace: time.Hour}
+	ctx := context.Background()
+
+	if _, err := s.AdminDeleteUser(ctx, "admin", "u1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := accounts.users["u1"].Status; got != postgres.UserStatusPendingDeletion {
+		t.Fatalf("status after delete = %q, want %q", got, postgres.UserStatusPendingDeletion)
+	}
+
+	if err := s.RestoreUser(ctx, "admin", "u1"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := accounts.users["u1"].Status; got != postgres.UserStatusActive {
+		t.Fatalf("status after restore = %q, want %q", got, postgres.UserStatusActive)
+	}
+	if want := []string{audit.ActionDeleted, audit.ActionUserRestored}; !slices.Equal(auditLog.actions, want) {
+		t.Fatalf("audit actions = %v, want %v", auditLog.actions, want)
+	}
+}
+
+type nopSender struct{}
+
+func (nopSender) Send(ctx context.Context, to, subject, body string) error { return nil }
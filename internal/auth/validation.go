@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"ahub/internal/password"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	loginEmailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	loginPhoneRegex = regexp.MustCompile(`^\+?[0-9()\-\s]{5,25}$`)
+	nameRegex       = regexp.MustCompile(`^\p{L}[\p{L}\p{M}' \-]*$`)
+)
+
+// Правила регистрируются в движке gin, поэтому одни и те же теги `binding`
+// проверяются и в ShouldBindJSON, и в validateStruct для вызовов AuthService не через HTTP.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	_ = v.RegisterValidation("login", func(fl validator.FieldLevel) bool {
+		s := strings.TrimSpace(fl.Field().String())
+		return loginEmailRegex.MatchString(s) || loginPhoneRegex.MatchString(s)
+	})
+
+	_ = v.RegisterValidation("name", func(fl validator.FieldLevel) bool {
+		return nameRegex.MatchString(fl.Field().String())
+	})
+}
+
+func validateStruct(obj any) error {
+	return binding.Validator.ValidateStruct(obj)
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// respondBindError отвечает 400; ошибки валидации раскладываются по полям в "details".
+func respondBindError(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "validation failed",
+		"details": fieldErrors(verrs),
+	})
+}
+
+// respondPolicyError отдаёт нарушения парольной политики в том же формате, что и ошибки валидации.
+func respondPolicyError(c *gin.Context, field string, perr *password.PolicyError) {
+	details := make([]FieldError, 0, len(perr.Violations))
+	for _, v := range perr.Violations {
+		details = append(details, FieldError{Field: field, Rule: v.Rule, Message: v.Message})
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "password does not meet policy",
+		"details": details,
+	})
+}
+
+func fieldErrors(verrs validator.ValidationErrors) []FieldError {
+	details := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		details = append(details, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return details
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return "is required when " + strings.ToLower(fe.Param()) + " is not set"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters", fe.Param())
+	case "numeric":
+		return "must contain only digits"
+	case "uuid":
+		return "must be a valid UUID"
+	case "email":
+		return "must be a valid email"
+	case "login":
+		return "must be a valid email or phone number"
+	case "name":
+		return "may contain only letters, spaces, hyphens and apostrophes"
+	default:
+		return "is invalid"
+	}
+}
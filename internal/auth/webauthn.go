@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/requestctx"
+	"ahub/storage/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	pendingWebAuthnRegistration = "webauthn_registration"
+	pendingWebAuthnLogin        = "webauthn_login"
+)
+
+var (
+	ErrWebAuthnFailed   = errors.New("webauthn verification failed")
+	ErrCredentialCloned = errors.New("authenticator sign counter did not increase, credential may be cloned")
+)
+
+type WebAuthnRegistrationData struct {
+	UserID  string               `json:"user_id"`
+	Session webauthn.SessionData `json:"session"`
+}
+
+// WebAuthnLoginData: MFAToken задан, если ключ используется как второй фактор после пароля.
+type WebAuthnLoginData struct {
+	Session  webauthn.SessionData `json:"session"`
+	MFAToken string               `json:"mfa_token,omitempty"`
+}
+
+// webauthnUser адаптирует пользователя к интерфейсу webauthn.User; user handle — его UUID.
+type webauthnUser struct {
+	user  *postgres.User
+	creds []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	if u.user.Email != nil {
+		return *u.user.Email
+	}
+	if u.user.Phone != nil {
+		return *u.user.Phone
+	}
+	return u.user.ID
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	return strings.TrimSpace(u.user.FirstName + " " + u.user.LastName)
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.creds
+}
+
+func (s *AuthService) BeginWebAuthnRegistration(ctx context.Context, userID string) (*protocol.CredentialCreation, string, error) {
+	user, err := s.loadWebAuthnUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// resident key нужен для входа без логина (passkey); уже зарегистрированные ключи исключаются
+	creation, session, err := s.webauthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+		webauthn.WithExclusions(webauthn.Credentials(user.creds).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.storage.SavePending(ctx, pendingWebAuthnRegistration, WebAuthnRegistrationData{
+		UserID:  userID,
+		Session: *session,
+	}, s.webauthnTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return creation, token, nil
+}
+
+func (s *AuthService) FinishWebAuthnRegistration(ctx context.Context, userID, token, name string, response []byte) error {
+	var data WebAuthnRegistrationData
+	if err := s.storage.GetPending(ctx, pendingWebAuthnRegistration, token, &data); err != nil {
+		return err
+	}
+
+	// челлендж одноразовый независимо от исхода проверки
+	_ = s.storage.DeletePending(ctx, pendingWebAuthnRegistration, token)
+
+	if data.UserID != userID {
+		return ErrPendingNotFound
+	}
+
+	user, err := s.loadWebAuthnUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return ErrWebAuthnFailed
+	}
+
+	cred, err := s.webauthn.CreateCredential(user, data.Session, parsed)
+	if err != nil {
+		return ErrWebAuthnFailed
+	}
+
+	raw, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+
+	err = s.keys.SaveWebAuthnCredential(ctx, &postgres.WebAuthnCredential{
+		UserID:       userID,
+		CredentialID: cred.ID,
+		Name:         name,
+		Credential:   string(raw),
+		SignCount:    int64(cred.Authenticator.SignCount),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:    userID,
+		Subject:  userID,
+		Action:   audit.ActionWebAuthnRegistered,
+		Metadata: map[string]any{"name": name},
+	})
+
+	return nil
+}
+
+// BeginWebAuthnLogin начинает вход по ключу. С mfaToken ключ проверяется как второй фактор;
+// иначе это вход без пароля: по логину или, если логин не указан, по passkey (discoverable).
+func (s *AuthService) BeginWebAuthnLogin(ctx context.Context, login, mfaToken string) (*protocol.CredentialAssertion, string, error) {
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		err       error
+	)
+
+	switch {
+	case mfaToken != "":
+		var challenge MFAChallenge
+		if err := s.storage.GetPending(ctx, pendingMFAChallenge, mfaToken, &challenge); err != nil {
+			return nil, "", err
+		}
+
+		user, err := s.loadWebAuthnUser(ctx, challenge.UserID)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(user.creds) == 0 {
+			return nil, "", ErrWebAuthnFailed
+		}
+
+		assertion, session, err = s.webauthn.BeginLogin(user)
+		if err != nil {
+			return nil, "", err
+		}
+
+	default:
+		user := s.webauthnUserByLogin(ctx, login)
+
+		// для неизвестного логина или пользователя без ключей ответ тот же, что для passkey,
+		// чтобы по нему нельзя было понять, зарегистрирован ли адрес
+		if user != nil && len(user.creds) > 0 {
+			assertion, session, err = s.webauthn.BeginLogin(user,
+				webauthn.WithUserVerification(protocol.VerificationRequired),
+			)
+		} else {
+			assertion, session, err = s.webauthn.BeginDiscoverableLogin(
+				webauthn.WithUserVerification(protocol.VerificationRequired),
+			)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	token, err := s.storage.SavePending(ctx, pendingWebAuthnLogin, WebAuthnLoginData{
+		Session:  *session,
+		MFAToken: mfaToken,
+	}, s.webauthnTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return assertion, token, nil
+}
+
+func (s *AuthService) FinishWebAuthnLogin(ctx context.Context, token string, response []byte) (*LoginResult, error) {
+	var data WebAuthnLoginData
+	if err := s.storage.GetPending(ctx, pendingWebAuthnLogin, token, &data); err != nil {
+		return nil, err
+	}
+
+	_ = s.storage.DeletePending(ctx, pendingWebAuthnLogin, token)
+
+	user, err := s.verifyWebAuthnAssertion(ctx, data.Session, response)
+	if err != nil {
+		return nil, err
+	}
+
+	// вход по passkey с проверкой пользователя (UV) уже двухфакторный, TOTP не запрашивается
+	amr := []string{AMRHardwareKey, AMRMFA}
+
+	if data.MFAToken != "" {
+		var challenge MFAChallenge
+		if err := s.storage.GetPending(ctx, pendingMFAChallenge, data.MFAToken, &challenge); err != nil {
+			return nil, err
+		}
+		if challenge.UserID != user.user.ID {
+			return nil, ErrWebAuthnFailed
+		}
+		_ = s.storage.DeletePending(ctx, pendingMFAChallenge, data.MFAToken)
+
+		amr = append(challenge.AMR, amr...)
+	}
+
+	return s.finishLogin(ctx, user.user.ID, newAuthContext(amr...))
+}
+
+// verifyWebAuthnAssertion проверяет подпись ключа и сохраняет новый счётчик. Неудачные
+// проверки идут в тот же счётчик блокировки, что и пароль; для passkey пользователь
+// известен только после разбора user handle.
+func (s *AuthService) verifyWebAuthnAssertion(ctx context.Context, session webauthn.SessionData, response []byte) (*webauthnUser, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, ErrWebAuthnFailed
+	}
+
+	ip := requestctx.From(ctx).IP
+
+	var (
+		user      *webauthnUser
+		cred      *webauthn.Credential
+		throttled error
+	)
+
+	if len(session.UserID) > 0 {
+		if err := s.lockout.Check(ctx, UserKey(string(session.UserID), ""), ip); err != nil {
+			return nil, err
+		}
+
+		user, err = s.loadWebAuthnUser(ctx, string(session.UserID))
+		if err != nil {
+			return nil, ErrWebAuthnFailed
+		}
+
+		cred, err = s.webauthn.ValidateLogin(user, session, parsed)
+	} else {
+		cred, err = s.webauthn.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			if err := s.lockout.Check(ctx, UserKey(string(userHandle), ""), ip); err != nil {
+				throttled = err
+				return nil, err
+			}
+
+			u, err := s.loadWebAuthnUser(ctx, string(userHandle))
+			if err != nil {
+				return nil, err
+			}
+			user = u
+			return u, nil
+		}, session, parsed)
+	}
+	if throttled != nil {
+		return nil, throttled
+	}
+	if err != nil {
+		if user != nil {
+			_, _ = s.lockout.Fail(ctx, UserKey(user.user.ID, ""), ip)
+		}
+		return nil, ErrWebAuthnFailed
+	}
+
+	if err := s.updateWebAuthnCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	_ = s.lockout.Reset(ctx, UserKey(user.user.ID, ""))
+
+	return user, nil
+}
+
+// updateWebAuthnCredential сохраняет новый счётчик подписей. Если счётчик не вырос,
+// библиотека ставит CloneWarning: такой ключ мог быть скопирован, вход отклоняется.
+func (s *AuthService) updateWebAuthnCredential(ctx context.Context, cred *webauthn.Credential) error {
+	if cred.Authenticator.CloneWarning {
+		return ErrCredentialCloned
+	}
+
+	raw, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+
+	return s.keys.UpdateWebAuthnCredential(ctx, cred.ID, int64(cred.Authenticator.SignCount), string(raw))
+}
+
+func (s *AuthService) webauthnUserByLogin(ctx context.Context, login string) *webauthnUser {
+	if login == "" {
+		return nil
+	}
+
+	id, err := s.ids.Normalize(login)
+	if err != nil {
+		return nil
+	}
+
+	info, err := s.logins.GetUserByLogin(ctx, id.Value)
+	if err != nil {
+		return nil
+	}
+
+	user, err := s.loadWebAuthnUser(ctx, info.ID)
+	if err != nil {
+		return nil
+	}
+
+	return user
+}
+
+func (s *AuthService) loadWebAuthnUser(ctx context.Context, userID string) (*webauthnUser, error) {
+	user, err := s.keys.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, postgres.ErrUserNotFound
+	}
+
+	rows, err := s.keys.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := make([]webauthn.Credential, 0, len(rows))
+	for _, row := range rows {
+		var cred webauthn.Credential
+		if err := json.Unmarshal([]byte(row.Credential), &cred); err != nil {
+			return nil, err
+		}
+		// счётчик в отдельной колонке — источник истины
+		cred.Authenticator.SignCount = uint32(row.SignCount)
+		creds = append(creds, cred)
+	}
+
+	return &webauthnUser{user: user, creds: creds}, nil
+}
+
+func (s *AuthService) hasWebAuthn(ctx context.Context, userID string) (bool, error) {
+	rows, err := s.keys.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
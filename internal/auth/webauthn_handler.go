@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"ahub/storage/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebAuthnBeginResponse struct {
+	Token   string `json:"token"`
+	Options any    `json:"options"`
+}
+
+// FinishWebAuthnRegistrationRequest: Credential — ответ navigator.credentials.create() как есть.
+type FinishWebAuthnRegistrationRequest struct {
+	Token      string          `json:"token" binding:"required,uuid"`
+	Name       string          `json:"name" binding:"max=64"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+// BeginWebAuthnLoginRequest: оба поля необязательны, пустой запрос — вход по passkey.
+type BeginWebAuthnLoginRequest struct {
+	Login    string `json:"login" binding:"omitempty,max=254,login"`
+	MFAToken string `json:"mfa_token" binding:"omitempty,uuid"`
+}
+
+type FinishWebAuthnLoginRequest struct {
+	Token      string          `json:"token" binding:"required,uuid"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+func (h *AuthHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	creation, token, err := h.service.BeginWebAuthnRegistration(ctx, c.GetString("user_id"))
+	if err != nil {
+		respondWebAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnBeginResponse{Token: token, Options: creation})
+}
+
+func (h *AuthHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	var req FinishWebAuthnRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.FinishWebAuthnRegistration(ctx, c.GetString("user_id"), req.Token, req.Name, req.Credential); err != nil {
+		respondWebAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "security key registered"})
+}
+
+func (h *AuthHandler) BeginWebAuthnLogin(c *gin.Context) {
+	var req BeginWebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	assertion, token, err := h.service.BeginWebAuthnLogin(ctx, req.Login, req.MFAToken)
+	if err != nil {
+		respondWebAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnBeginResponse{Token: token, Options: assertion})
+}
+
+func (h *AuthHandler) FinishWebAuthnLogin(c *gin.Context) {
+	var req FinishWebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.service.FinishWebAuthnLogin(ctx, req.Token, req.Credential)
+	if err != nil {
+		respondWebAuthnError(c, err)
+		return
+	}
+
+	respondLogin(c, result)
+}
+
+func respondWebAuthnError(c *gin.Context, err error) {
+	if respondThrottled(c, err) {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrPendingNotFound), errors.Is(err, ErrWebAuthnFailed),
+		errors.Is(err, ErrCredentialCloned):
+		status = http.StatusUnauthorized
+	case errors.Is(err, postgres.ErrCredentialExists):
+		status = http.StatusConflict
+	case errors.Is(err, postgres.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAccountDisabled):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrAccountLocked):
+		status = http.StatusLocked
+	}
+
+	c.JSON(status, gin.H{"error": err.Error()})
+}
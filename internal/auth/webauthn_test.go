@@ -0,0 +1,496 @@
+package auth
+
+import (
+	"ahub/internal/audit"
+	"ahub/internal/identifier"
+	storagebd "ahub/storage"
+	"ahub/storage/postgres"
+	redisstore "ahub/storage/redis"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	testRPID   = "ahub.example"
+	testOrigin = "https://ahub.example"
+)
+
+// softAuthenticator — программный ES256-ключ с attestation "none", как у платформенных
+// аутентификаторов. Хранит один resident key.
+type softAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	credID     []byte
+	userHandle []byte
+	signCount  uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credID := make([]byte, 16)
+	if _, err := rand.Read(credID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{key: key, credID: credID}
+}
+
+func (a *softAuthenticator) authData(attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(testRPID))
+
+	// UP и UV; AT — если в данных есть новый ключ
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(rpHash[:])
+	buf.WriteByte(flags)
+	_ = binary.Write(&buf, binary.BigEndian, a.signCount)
+
+	if attested {
+		buf.Write(make([]byte, 16)) // AAGUID
+		_ = binary.Write(&buf, binary.BigEndian, uint16(len(a.credID)))
+		buf.Write(a.credID)
+
+		pub, _ := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+			PublicKeyData: webauthncose.PublicKeyData{
+				KeyType:   int64(webauthncose.EllipticKey),
+				Algorithm: int64(webauthncose.AlgES256),
+			},
+			Curve:  int64(webauthncose.P256),
+			XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+			YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+		})
+		buf.Write(pub)
+	}
+
+	return buf.Bytes()
+}
+
+func clientData(typ string, challenge protocol.URLEncodedBase64) []byte {
+	raw, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	return raw
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// create отвечает на navigator.credentials.create().
+func (a *softAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	t.Helper()
+
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+	a.signCount = 0
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(map[string]any{
+		"id":    b64(a.credID),
+		"rawId": b64(a.credID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData("webauthn.create", creation.Response.Challenge)),
+			"attestationObject": b64(attestation),
+		},
+	})
+	return raw
+}
+
+// get отвечает на navigator.credentials.get(); каждая подпись увеличивает счётчик.
+func (a *softAuthenticator) get(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	t.Helper()
+
+	a.signCount++
+	return a.sign(t, assertion, a.key)
+}
+
+func (a *softAuthenticator) sign(t *testing.T, assertion *protocol.CredentialAssertion, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+
+	data := a.authData(false)
+	client := clientData("webauthn.get", assertion.Response.Challenge)
+	clientHash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(append([]byte{}, data...), clientHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(map[string]any{
+		"id":    b64(a.credID),
+		"rawId": b64(a.credID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(client),
+			"authenticatorData": b64(data),
+			"signature":         b64(sig),
+			"userHandle":        b64(a.userHandle),
+		},
+	})
+	return raw
+}
+
+// fakeKeys хранит пользователей и ключи в памяти, как postgres-хранилище; вход ищет
+// пользователя по email.
+type fakeKeys struct {
+	mu    sync.Mutex
+	users map[string]*postgres.User
+	creds []postgres.WebAuthnCredential
+}
+
+func (f *fakeKeys) GetUser(ctx context.Context, userID string) (*postgres.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, postgres.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *fakeKeys) GetUserByLogin(ctx context.Context, login string) (*postgres.UserInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, u := range f.users {
+		if u.Email != nil && *u.Email == login {
+			return &postgres.UserInfo{ID: u.ID, PasswordHash: u.PasswordHash}, nil
+		}
+	}
+	return nil, postgres.ErrUserNotFound
+}
+
+func (f *fakeKeys) CreateLoginEvent(ctx context.Context, event *postgres.LoginEvent) error {
+	return nil
+}
+
+func (f *fakeKeys) ListWebAuthnCredentials(ctx context.Context, userID string) ([]postgres.WebAuthnCredential, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var rows []postgres.WebAuthnCredential
+	for _, c := range f.creds {
+		if c.UserID == userID {
+			rows = append(rows, c)
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeKeys) SaveWebAuthnCredential(ctx context.Context, cred *postgres.WebAuthnCredential) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range f.creds {
+		if bytes.Equal(c.CredentialID, cred.CredentialID) {
+			return postgres.ErrCredentialExists
+		}
+	}
+	f.creds = append(f.creds, *cred)
+	return nil
+}
+
+func (f *fakeKeys) UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, signCount int64, credential string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.creds {
+		if bytes.Equal(f.creds[i].CredentialID, credentialID) {
+			f.creds[i].SignCount = signCount
+			f.creds[i].Credential = credential
+			return nil
+		}
+	}
+	return postgres.ErrUserNotFound
+}
+
+type recordingAudit struct {
+	fakeAudit
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (r *recordingAudit) Record(ctx context.Context, e audit.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recordingAudit) RecordAsync(ctx context.Context, e audit.Event) error {
+	return r.Record(ctx, e)
+}
+
+type webauthnFixture struct {
+	service *AuthService
+	keys    *fakeKeys
+	audit   *recordingAudit
+	user    *postgres.User
+}
+
+func newWebAuthnFixture(t *testing.T) *webauthnFixture {
+	t.Helper()
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "ahub",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email := "owner@known.example"
+	user := &postgres.User{ID: "9b2d0f0e-6c1a-4d8e-a1f2-3c4b5d6e7f80", FirstName: "Anna", Email: &email, Status: postgres.UserStatusActive}
+
+	rdb := newTestRedis(t)
+	keys := &fakeKeys{users: map[string]*postgres.User{user.ID: user}}
+	rec := &recordingAudit{}
+
+	return &webauthnFixture{
+		service: &AuthService{
+			storage:     NewStorage(&storagebd.Storage{Redis: &redisstore.Storage{Client: rdb}}),
+			logins:      keys,
+			keys:        keys,
+			auditLog:    rec,
+			ids:         identifier.NewNormalizer("RU"),
+			lockout:     newTestLockout(rdb, 3),
+			webauthn:    wa,
+			webauthnTTL: time.Minute,
+		},
+		keys:  keys,
+		audit: rec,
+		user:  user,
+	}
+}
+
+func (f *webauthnFixture) register(t *testing.T, a *softAuthenticator) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	creation, token, err := f.service.BeginWebAuthnRegistration(ctx, f.user.ID)
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+
+	if err := f.service.FinishWebAuthnRegistration(ctx, f.user.ID, token, "laptop", a.create(t, creation)); err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+}
+
+// beginLogin начинает вход и отдаёт сессию так, как её прочитает FinishWebAuthnLogin.
+func (f *webauthnFixture) beginLogin(t *testing.T, login, mfaToken string) (*protocol.CredentialAssertion, webauthn.SessionData) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	assertion, token, err := f.service.BeginWebAuthnLogin(ctx, login, mfaToken)
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+
+	var data WebAuthnLoginData
+	if err := f.service.storage.GetPending(ctx, pendingWebAuthnLogin, token, &data); err != nil {
+		t.Fatalf("pending login: %v", err)
+	}
+
+	return assertion, data.Session
+}
+
+func TestWebAuthnRegistration(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	a := newSoftAuthenticator(t)
+
+	f.register(t, a)
+
+	if len(f.keys.creds) != 1 {
+		t.Fatalf("stored %d credentials, want 1", len(f.keys.creds))
+	}
+	stored := f.keys.creds[0]
+	if stored.UserID != f.user.ID || !bytes.Equal(stored.CredentialID, a.credID) || stored.Name != "laptop" {
+		t.Fatalf("unexpected credential: %+v", stored)
+	}
+
+	if len(f.audit.events) != 1 || f.audit.events[0].Action != audit.ActionWebAuthnRegistered {
+		t.Fatalf("audit events: %+v", f.audit.events)
+	}
+
+	// тот же ключ повторно не регистрируется: он в списке исключений
+	ctx := context.Background()
+	creation, _, err := f.service.BeginWebAuthnRegistration(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creation.Response.CredentialExcludeList) != 1 {
+		t.Fatalf("exclude list: %+v", creation.Response.CredentialExcludeList)
+	}
+}
+
+func TestWebAuthnRegistrationRejectsForeignChallenge(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	a := newSoftAuthenticator(t)
+	ctx := context.Background()
+
+	creation, token, err := f.service.BeginWebAuthnRegistration(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.service.FinishWebAuthnRegistration(ctx, "another-user", token, "laptop", a.create(t, creation))
+	if !errors.Is(err, ErrPendingNotFound) {
+		t.Fatalf("got %v, want ErrPendingNotFound", err)
+	}
+
+	// челлендж одноразовый: после неудачи им не может воспользоваться и владелец
+	err = f.service.FinishWebAuthnRegistration(ctx, f.user.ID, token, "laptop", a.create(t, creation))
+	if !errors.Is(err, ErrPendingNotFound) {
+		t.Fatalf("reused challenge: got %v, want ErrPendingNotFound", err)
+	}
+}
+
+func TestWebAuthnLogin(t *testing.T) {
+	tests := []struct {
+		name  string
+		login string
+	}{
+		{name: "by login", login: "owner@known.example"},
+		{name: "passkey", login: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWebAuthnFixture(t)
+			a := newSoftAuthenticator(t)
+			f.register(t, a)
+
+			assertion, session := f.beginLogin(t, tt.login, "")
+			// по логину сессия привязана к пользователю, passkey определяется по user handle
+			if discoverable := len(session.UserID) == 0; discoverable != (tt.login == "") {
+				t.Fatalf("session user %q for login %q", session.UserID, tt.login)
+			}
+
+			user, err := f.service.verifyWebAuthnAssertion(context.Background(), session, a.get(t, assertion))
+			if err != nil {
+				t.Fatalf("verify assertion: %v", err)
+			}
+			if user.user.ID != f.user.ID {
+				t.Fatalf("logged in as %s, want %s", user.user.ID, f.user.ID)
+			}
+			if got := f.keys.creds[0].SignCount; got != int64(a.signCount) {
+				t.Fatalf("stored sign count %d, want %d", got, a.signCount)
+			}
+		})
+	}
+}
+
+func TestWebAuthnLoginAsSecondFactor(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	a := newSoftAuthenticator(t)
+	f.register(t, a)
+
+	ctx := context.Background()
+	mfaToken, err := f.service.storage.SavePending(ctx, pendingMFAChallenge, MFAChallenge{UserID: f.user.ID, AMR: []string{AMRPassword}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion, session := f.beginLogin(t, "", mfaToken)
+	if len(assertion.Response.AllowedCredentials) != 1 {
+		t.Fatalf("allowed credentials: %+v", assertion.Response.AllowedCredentials)
+	}
+
+	if _, err := f.service.verifyWebAuthnAssertion(ctx, session, a.get(t, assertion)); err != nil {
+		t.Fatalf("verify assertion: %v", err)
+	}
+}
+
+func TestWebAuthnLoginRejectsClonedCredential(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	a := newSoftAuthenticator(t)
+	f.register(t, a)
+
+	ctx := context.Background()
+
+	assertion, session := f.beginLogin(t, *f.user.Email, "")
+	if _, err := f.service.verifyWebAuthnAssertion(ctx, session, a.get(t, assertion)); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	// копия ключа подписывает со старым счётчиком
+	assertion, session = f.beginLogin(t, *f.user.Email, "")
+	_, err := f.service.verifyWebAuthnAssertion(ctx, session, a.sign(t, assertion, a.key))
+	if !errors.Is(err, ErrCredentialCloned) {
+		t.Fatalf("got %v, want ErrCredentialCloned", err)
+	}
+}
+
+func TestWebAuthnLoginFailuresCountTowardLockout(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	a := newSoftAuthenticator(t)
+	f.register(t, a)
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	// подпись чужим ключом; третья неудача блокирует вход
+	for range 3 {
+		assertion, session := f.beginLogin(t, *f.user.Email, "")
+		a.signCount++
+		if _, err := f.service.verifyWebAuthnAssertion(ctx, session, a.sign(t, assertion, other)); !errors.Is(err, ErrWebAuthnFailed) {
+			t.Fatalf("got %v, want ErrWebAuthnFailed", err)
+		}
+	}
+
+	// правильный ключ тоже отклоняется, пока действует блокировка
+	for _, login := range []string{*f.user.Email, ""} {
+		assertion, session := f.beginLogin(t, login, "")
+		_, err := f.service.verifyWebAuthnAssertion(ctx, session, a.get(t, assertion))
+
+		var throttled *ThrottledError
+		if !errors.As(err, &throttled) || !throttled.Locked {
+			t.Fatalf("login %q: got %v, want locked", login, err)
+		}
+	}
+}
@@ -11,6 +11,24 @@ import (
 type JWTConfig struct {
 	Secret string `yaml:"secret" env:"JWT_SECRET" envDefault:"your-very-random-secret-key-here"`
 	TTL    string `yaml:"ttl" env:"JWT_TTL" envDefault:"15m"`
+	// StepUpTTL — срок жизни токена после /auth/reauthenticate,
+	// RecentAuthMaxAge — насколько давним может быть вход для чувствительных операций
+	StepUpTTL        string `yaml:"step_up_ttl" env:"JWT_STEP_UP_TTL" envDefault:"5m"`
+	RecentAuthMaxAge string `yaml:"recent_auth_max_age" env:"JWT_RECENT_AUTH_MAX_AGE" envDefault:"10m"`
+	// ImpersonationTTL — срок жизни токена, который администратор получает для входа от имени пользователя
+	ImpersonationTTL string `yaml:"impersonation_ttl" env:"JWT_IMPERSONATION_TTL" envDefault:"10m"`
+}
+
+func (j *JWTConfig) StepUpTTLDuration() time.Duration {
+	return mustParseDuration("jwt step-up ttl", j.StepUpTTL)
+}
+
+func (j *JWTConfig) ImpersonationTTLDuration() time.Duration {
+	return mustParseDuration("jwt impersonation ttl", j.ImpersonationTTL)
+}
+
+func (j *JWTConfig) RecentAuthMaxAgeDuration() time.Duration {
+	return mustParseDuration("jwt recent auth max age", j.RecentAuthMaxAge)
 }
 
 type PostgresConfig struct {
@@ -30,6 +48,169 @@ type RedisConfig struct {
 	TTL      string `yaml:"ttl" env:"REDIS_TTL" envDefault:"300s"`
 }
 
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST" envDefault:""`
+	Port     int    `yaml:"port" env:"SMTP_PORT" envDefault:"587"`
+	User     string `yaml:"user" env:"SMTP_USER" envDefault:""`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" envDefault:""`
+	From     string `yaml:"from" env:"SMTP_FROM" envDefault:""`
+}
+
+type AccountConfig struct {
+	DeletionGrace string `yaml:"deletion_grace" env:"ACCOUNT_DELETION_GRACE" envDefault:"720h"`
+	PurgeInterval string `yaml:"purge_interval" env:"ACCOUNT_PURGE_INTERVAL" envDefault:"1h"`
+	// ActivityRetention — сколько хранить историю входов (login_events)
+	ActivityRetention string `yaml:"activity_retention" env:"ACCOUNT_ACTIVITY_RETENTION" envDefault:"2160h"`
+	// AuditRetention — сколько хранить журнал аудита; старые записи удаляются с начала цепочки, 0 — хранить всегда
+	AuditRetention string `yaml:"audit_retention" env:"ACCOUNT_AUDIT_RETENTION" envDefault:"8760h"`
+	// StatusCacheTTL — как долго AuthMiddleware доверяет закэшированному статусу аккаунта; 0 отключает проверку
+	StatusCacheTTL string `yaml:"status_cache_ttl" env:"ACCOUNT_STATUS_CACHE_TTL" envDefault:"30s"`
+}
+
+type LoginConfig struct {
+	DefaultRegion string `yaml:"default_region" env:"LOGIN_DEFAULT_REGION" envDefault:"RU"`
+	OTPEnabled    bool   `yaml:"otp_enabled" env:"LOGIN_OTP_ENABLED" envDefault:"false"`
+}
+
+type Argon2Config struct {
+	Memory      uint32 `yaml:"memory" env:"ARGON2_MEMORY" envDefault:"65536"`
+	Iterations  uint32 `yaml:"iterations" env:"ARGON2_ITERATIONS" envDefault:"3"`
+	Parallelism uint8  `yaml:"parallelism" env:"ARGON2_PARALLELISM" envDefault:"2"`
+	SaltLength  uint32 `yaml:"salt_length" env:"ARGON2_SALT_LENGTH" envDefault:"16"`
+	KeyLength   uint32 `yaml:"key_length" env:"ARGON2_KEY_LENGTH" envDefault:"32"`
+}
+
+type HashPoolConfig struct {
+	Concurrency int    `yaml:"concurrency" env:"HASH_POOL_CONCURRENCY" envDefault:"4"`
+	QueueDepth  int    `yaml:"queue_depth" env:"HASH_POOL_QUEUE_DEPTH" envDefault:"64"`
+	RetryAfter  string `yaml:"retry_after" env:"HASH_POOL_RETRY_AFTER" envDefault:"1s"`
+}
+
+type PasswordConfig struct {
+	MinLength     int            `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" envDefault:"10"`
+	MaxLength     int            `yaml:"max_length" env:"PASSWORD_MAX_LENGTH" envDefault:"128"`
+	RequireLower  bool           `yaml:"require_lower" env:"PASSWORD_REQUIRE_LOWER" envDefault:"true"`
+	RequireUpper  bool           `yaml:"require_upper" env:"PASSWORD_REQUIRE_UPPER" envDefault:"false"`
+	RequireDigit  bool           `yaml:"require_digit" env:"PASSWORD_REQUIRE_DIGIT" envDefault:"true"`
+	RequireSymbol bool           `yaml:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL" envDefault:"false"`
+	MinScore      int            `yaml:"min_score" env:"PASSWORD_MIN_SCORE" envDefault:"2"`
+	BreachedDir   string         `yaml:"breached_dir" env:"PASSWORD_BREACHED_DIR" envDefault:""`
+	Argon2        Argon2Config   `yaml:"argon2"`
+	HashPool      HashPoolConfig `yaml:"hash_pool"`
+}
+
+type LockoutConfig struct {
+	FreeAttempts int    `yaml:"free_attempts" env:"LOCKOUT_FREE_ATTEMPTS" envDefault:"3"`
+	BaseDelay    string `yaml:"base_delay" env:"LOCKOUT_BASE_DELAY" envDefault:"1s"`
+	MaxDelay     string `yaml:"max_delay" env:"LOCKOUT_MAX_DELAY" envDefault:"30s"`
+	Threshold    int    `yaml:"threshold" env:"LOCKOUT_THRESHOLD" envDefault:"10"`
+	IPThreshold  int    `yaml:"ip_threshold" env:"LOCKOUT_IP_THRESHOLD" envDefault:"100"`
+	Window       string `yaml:"window" env:"LOCKOUT_WINDOW" envDefault:"15m"`
+	Duration     string `yaml:"duration" env:"LOCKOUT_DURATION" envDefault:"15m"`
+}
+
+type RateLimitPolicy struct {
+	Name   string `yaml:"name"`
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+	Key    string `yaml:"key"` // ip, login или user
+	Limit  int    `yaml:"limit"`
+	Period string `yaml:"period"`
+	Burst  int    `yaml:"burst"`
+}
+
+type RateLimitConfig struct {
+	Enabled  bool              `yaml:"enabled" env:"RATE_LIMIT_ENABLED" envDefault:"true"`
+	Policies []RateLimitPolicy `yaml:"policies"`
+}
+
+func (p *RateLimitPolicy) PeriodDuration() time.Duration {
+	return mustParseDuration("rate limit period", p.Period)
+}
+
+type MFAConfig struct {
+	Issuer        string `yaml:"issuer" env:"MFA_ISSUER" envDefault:"AHUB"`
+	EncryptionKey string `yaml:"encryption_key" env:"MFA_ENCRYPTION_KEY" envDefault:""`
+	ChallengeTTL  string `yaml:"challenge_ttl" env:"MFA_CHALLENGE_TTL" envDefault:"5m"`
+	// DeviceSecret подписывает cookie доверенного устройства; пусто — запоминание устройств отключено
+	DeviceSecret string `yaml:"device_secret" env:"MFA_DEVICE_SECRET" envDefault:""`
+	DeviceTTL    string `yaml:"device_ttl" env:"MFA_DEVICE_TTL" envDefault:"720h"`
+}
+
+func (m *MFAConfig) ChallengeTTLDuration() time.Duration {
+	return mustParseDuration("mfa challenge ttl", m.ChallengeTTL)
+}
+
+func (m *MFAConfig) DeviceTTLDuration() time.Duration {
+	return mustParseDuration("mfa device ttl", m.DeviceTTL)
+}
+
+type WebAuthnConfig struct {
+	RPID          string   `yaml:"rp_id" env:"WEBAUTHN_RP_ID" envDefault:"localhost"`
+	RPDisplayName string   `yaml:"rp_display_name" env:"WEBAUTHN_RP_DISPLAY_NAME" envDefault:"AHUB"`
+	RPOrigins     []string `yaml:"rp_origins" env:"WEBAUTHN_RP_ORIGINS" env-separator:","`
+	ChallengeTTL  string   `yaml:"challenge_ttl" env:"WEBAUTHN_CHALLENGE_TTL" envDefault:"5m"`
+}
+
+func (w *WebAuthnConfig) ChallengeTTLDuration() time.Duration {
+	return mustParseDuration("webauthn challenge ttl", w.ChallengeTTL)
+}
+
+// MagicLinkConfig: ссылка ведёт на BaseURL/auth/magic/<token>, после входа —
+// редирект на один из ReturnURLs (первый используется по умолчанию).
+type MagicLinkConfig struct {
+	Enabled    bool     `yaml:"enabled" env:"MAGIC_LINK_ENABLED" envDefault:"false"`
+	BaseURL    string   `yaml:"base_url" env:"MAGIC_LINK_BASE_URL" envDefault:"http://localhost:8080"`
+	Secret     string   `yaml:"secret" env:"MAGIC_LINK_SECRET" envDefault:""`
+	TTL        string   `yaml:"ttl" env:"MAGIC_LINK_TTL" envDefault:"15m"`
+	ReturnURLs []string `yaml:"return_urls" env:"MAGIC_LINK_RETURN_URLS" env-separator:","`
+}
+
+func (m *MagicLinkConfig) TTLDuration() time.Duration {
+	return mustParseDuration("magic link ttl", m.TTL)
+}
+
+// LoginAlertConfig: уведомление о входе с нового устройства содержит ссылку
+// BaseURL/auth/not-me/<token>; сессия завершается после подтверждения на открывшейся странице.
+type LoginAlertConfig struct {
+	Enabled bool   `yaml:"enabled" env:"LOGIN_ALERTS_ENABLED" envDefault:"true"`
+	BaseURL string `yaml:"base_url" env:"LOGIN_ALERTS_BASE_URL" envDefault:"http://localhost:8080"`
+	LinkTTL string `yaml:"link_ttl" env:"LOGIN_ALERTS_LINK_TTL" envDefault:"720h"`
+}
+
+func (l *LoginAlertConfig) LinkTTLDuration() time.Duration {
+	return mustParseDuration("login alert link ttl", l.LinkTTL)
+}
+
+// WebhookSubscriber получает события из Events (пусто — все события) на URL,
+// тело подписывается HMAC-SHA256 с Secret.
+type WebhookSubscriber struct {
+	Name   string   `yaml:"name"`
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
+}
+
+type WebhooksConfig struct {
+	PollInterval string              `yaml:"poll_interval" env:"WEBHOOKS_POLL_INTERVAL" envDefault:"5s"`
+	Timeout      string              `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" envDefault:"10s"`
+	MaxAttempts  int                 `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" envDefault:"8"`
+	BatchSize    int                 `yaml:"batch_size" env:"WEBHOOKS_BATCH_SIZE" envDefault:"100"`
+	Subscribers  []WebhookSubscriber `yaml:"subscribers"`
+}
+
+func (w *WebhooksConfig) PollIntervalDuration() time.Duration {
+	return mustParseDuration("webhooks poll interval", w.PollInterval)
+}
+
+func (w *WebhooksConfig) TimeoutDuration() time.Duration {
+	return mustParseDuration("webhooks timeout", w.Timeout)
+}
+
+type AdminConfig struct {
+	APIKey string `yaml:"api_key" env:"ADMIN_API_KEY" envDefault:""`
+}
+
 type Config struct {
 	Env        string         `yaml:"env" env:"ENV" envDefault:"local" envRequired:"true"`
 	Postgres   PostgresConfig `yaml:"postgres"`
@@ -38,8 +219,23 @@ type Config struct {
 		Address     string `yaml:"address" env:"HTTP_ADDRESS" envDefault:"localhost:8080"`
 		Timeout     string `yaml:"timeout" env:"HTTP_TIMEOUT" envDefault:"4s"`
 		IdleTimeout string `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" envDefault:"60s"`
+		// TrustedProxies — адреса и подсети прокси, которым можно верить в X-Forwarded-For;
+		// пустой список — IP клиента берётся из соединения
+		TrustedProxies []string `yaml:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES" env-separator:","`
 	} `yaml:"http_server"`
-	JWT JWTConfig `yaml:"jwt"`
+	JWT         JWTConfig        `yaml:"jwt"`
+	SMTP        SMTPConfig       `yaml:"smtp"`
+	Account     AccountConfig    `yaml:"account"`
+	Login       LoginConfig      `yaml:"login"`
+	Password    PasswordConfig   `yaml:"password"`
+	Lockout     LockoutConfig    `yaml:"lockout"`
+	Admin       AdminConfig      `yaml:"admin"`
+	RateLimit   RateLimitConfig  `yaml:"rate_limit"`
+	MFA         MFAConfig        `yaml:"mfa"`
+	WebAuthn    WebAuthnConfig   `yaml:"webauthn"`
+	MagicLink   MagicLinkConfig  `yaml:"magic_link"`
+	LoginAlerts LoginAlertConfig `yaml:"login_alerts"`
+	Webhooks    WebhooksConfig   `yaml:"webhooks"`
 }
 
 func (r *RedisConfig) TTLDuration() time.Duration {
@@ -58,6 +254,66 @@ func (c *Config) JWTTTLDuration() time.Duration {
 	return d
 }
 
+func (a *AccountConfig) DeletionGraceDuration() time.Duration {
+	d, err := time.ParseDuration(a.DeletionGrace)
+	if err != nil {
+		log.Fatalf("invalid account deletion grace duration: %s", err)
+	}
+	return d
+}
+
+func (a *AccountConfig) ActivityRetentionDuration() time.Duration {
+	return mustParseDuration("account activity retention", a.ActivityRetention)
+}
+
+func (a *AccountConfig) AuditRetentionDuration() time.Duration {
+	return mustParseDuration("account audit retention", a.AuditRetention)
+}
+
+func (a *AccountConfig) StatusCacheTTLDuration() time.Duration {
+	return mustParseDuration("account status cache TTL", a.StatusCacheTTL)
+}
+
+func (a *AccountConfig) PurgeIntervalDuration() time.Duration {
+	d, err := time.ParseDuration(a.PurgeInterval)
+	if err != nil {
+		log.Fatalf("invalid account purge interval: %s", err)
+	}
+	return d
+}
+
+func (h *HashPoolConfig) RetryAfterDuration() time.Duration {
+	d, err := time.ParseDuration(h.RetryAfter)
+	if err != nil {
+		log.Fatalf("invalid hash pool retry-after duration: %s", err)
+	}
+	return d
+}
+
+func (l *LockoutConfig) BaseDelayDuration() time.Duration {
+	return mustParseDuration("lockout base delay", l.BaseDelay)
+}
+
+func (l *LockoutConfig) MaxDelayDuration() time.Duration {
+	return mustParseDuration("lockout max delay", l.MaxDelay)
+}
+
+func (l *LockoutConfig) WindowDuration() time.Duration {
+	return mustParseDuration("lockout window", l.Window)
+}
+
+func (l *LockoutConfig) LockDuration() time.Duration {
+	return mustParseDuration("lockout duration", l.Duration)
+}
+
+func mustParseDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s duration: %s", name, err)
+	}
+	return d
+}
+
 func MustLoad() *Config {
 	configPath := os.Getenv("CONFIG_PATH")
 
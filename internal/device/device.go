@@ -0,0 +1,87 @@
+package device
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// Info — грубое описание клиента: браузер, ОС и сеть. Мелкие различия (версия браузера,
+// последний октет адреса) не делают устройство «новым».
+type Info struct {
+	Browser string
+	OS      string
+	Network string
+}
+
+func Parse(userAgent, ip string) Info {
+	return Info{
+		Browser: browser(userAgent),
+		OS:      operatingSystem(userAgent),
+		Network: network(ip),
+	}
+}
+
+// Key — стабильный ключ для сравнения с историей входов.
+func (i Info) Key() string {
+	sum := sha256.Sum256([]byte(i.Browser + "|" + i.OS + "|" + i.Network))
+	return hex.EncodeToString(sum[:])
+}
+
+// String — описание для уведомления, например "Chrome on Windows".
+func (i Info) String() string {
+	return i.Browser + " on " + i.OS
+}
+
+// Порядок важен: UA Edge и Opera содержат "Chrome", UA Chrome содержит "Safari".
+var browsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"CriOS/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+var systems = []struct{ token, name string }{
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+func browser(ua string) string {
+	for _, b := range browsers {
+		if strings.Contains(ua, b.token) {
+			return b.name
+		}
+	}
+	return "Unknown browser"
+}
+
+func operatingSystem(ua string) string {
+	for _, s := range systems {
+		if strings.Contains(ua, s.token) {
+			return s.name
+		}
+	}
+	return "unknown OS"
+}
+
+// network сводит адрес к подсети (/24 для IPv4, /48 для IPv6), чтобы смена адреса
+// внутри одного провайдера не считалась новым устройством.
+func network(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
@@ -1,21 +1,28 @@
 package email
 
 import (
+	"ahub/internal/config"
+	"context"
+	"fmt"
 	"net/smtp"
 )
 
-func SendEmail(to, subject, body string) error {
-	from := "your.email@gmail.com"
-	password := "app_password"
+type Sender struct {
+	cfg config.SMTPConfig
+}
 
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
+func NewSender(cfg config.SMTPConfig) *Sender {
+	return &Sender{cfg: cfg}
+}
 
-	msg := "From: " + from + "\n" +
+func (s *Sender) Send(_ context.Context, to, subject, body string) error {
+	msg := "From: " + s.cfg.From + "\n" +
 		"To: " + to + "\n" +
 		"Subject: " + subject + "\n\n" +
 		body
 
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, []byte(msg))
+	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
+
+	auth := smtp.PlainAuth("", s.cfg.User, s.cfg.Password, s.cfg.Host)
+	return smtp.SendMail(addr, auth, s.cfg.From, []string{to}, []byte(msg))
 }
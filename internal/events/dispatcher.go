@@ -0,0 +1,216 @@
+package events
+
+import (
+	"ahub/internal/config"
+	"ahub/storage/postgres"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// deliveryStore — часть хранилища, с которой работает Dispatcher.
+type deliveryStore interface {
+	FanOutOutboxEvents(ctx context.Context, limit int, subscribers func(eventType string) []string) (int, error)
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]postgres.DueDelivery, error)
+	RecordDeliveryAttempt(ctx context.Context, attempt *postgres.WebhookAttempt, outcome postgres.DeliveryOutcome) error
+}
+
+// Dispatcher разбирает outbox: раскладывает события по подписчикам и доставляет их
+// с повторами по расписанию backoff. Каждая попытка пишется в журнал доставки.
+type Dispatcher struct {
+	storage     deliveryStore
+	client      *http.Client
+	subscribers []config.WebhookSubscriber
+	interval    time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	batchSize   int
+	log         *slog.Logger
+}
+
+// NewDispatcher: client можно подменить (например, на клиент httptest-сервера); nil — клиент с таймаутом из cfg.
+func NewDispatcher(storage *postgres.Storage, cfg config.WebhooksConfig, client *http.Client, log *slog.Logger) *Dispatcher {
+	return newDispatcher(storage, cfg, client, log)
+}
+
+func newDispatcher(storage deliveryStore, cfg config.WebhooksConfig, client *http.Client, log *slog.Logger) *Dispatcher {
+	timeout := cfg.TimeoutDuration()
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+
+	return &Dispatcher{
+		storage:     storage,
+		client:      client,
+		subscribers: cfg.Subscribers,
+		interval:    cfg.PollIntervalDuration(),
+		timeout:     timeout,
+		maxAttempts: cfg.MaxAttempts,
+		batchSize:   cfg.BatchSize,
+		log:         log,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.Tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick выполняет один проход: раскладку новых событий и доставку всего, чей срок наступил.
+func (d *Dispatcher) Tick(ctx context.Context) {
+	if _, err := d.storage.FanOutOutboxEvents(ctx, d.batchSize, d.subscribersFor); err != nil {
+		d.log.Error("fan out outbox events", slog.String("error", err.Error()))
+	}
+
+	// доставки забираются по одной: аренда с запасом покрывает таймаут одного запроса,
+	// и пока идёт отправка, остальные доставки может забрать другой экземпляр
+	for range d.batchSize {
+		if ctx.Err() != nil {
+			return
+		}
+
+		due, err := d.storage.ClaimDueDeliveries(ctx, time.Now(), 2*d.timeout, 1)
+		if err != nil {
+			d.log.Error("claim webhook deliveries", slog.String("error", err.Error()))
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		d.deliver(ctx, due[0])
+	}
+}
+
+func (d *Dispatcher) subscribersFor(eventType string) []string {
+	var names []string
+	for _, s := range d.subscribers {
+		if len(s.Events) == 0 || slices.Contains(s.Events, eventType) {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+func (d *Dispatcher) subscriber(name string) (config.WebhookSubscriber, bool) {
+	for _, s := range d.subscribers {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return config.WebhookSubscriber{}, false
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, due postgres.DueDelivery) {
+	attempt := &postgres.WebhookAttempt{
+		EventID:    due.EventID,
+		Subscriber: due.Subscriber,
+		Attempt:    due.Attempts + 1,
+	}
+
+	sub, ok := d.subscriber(due.Subscriber)
+	if !ok {
+		// подписчика убрали из конфигурации — повторять некуда
+		attempt.Error = "subscriber is not configured"
+		d.record(ctx, attempt, postgres.DeliveryOutcome{GaveUp: true})
+		return
+	}
+	attempt.URL = sub.URL
+
+	start := time.Now()
+	status, err := d.send(ctx, sub, due)
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	attempt.StatusCode = status
+
+	if err == nil {
+		d.record(ctx, attempt, postgres.DeliveryOutcome{Delivered: true})
+		return
+	}
+
+	attempt.Error = err.Error()
+	if attempt.Attempt >= d.maxAttempts {
+		d.log.Warn("webhook delivery abandoned",
+			slog.String("subscriber", sub.Name),
+			slog.String("event_id", due.EventID),
+			slog.String("error", attempt.Error),
+		)
+		d.record(ctx, attempt, postgres.DeliveryOutcome{GaveUp: true})
+		return
+	}
+
+	d.record(ctx, attempt, postgres.DeliveryOutcome{NextAttemptAt: time.Now().Add(backoff(attempt.Attempt))})
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub config.WebhookSubscriber, due postgres.DueDelivery) (int, error) {
+	body, err := json.Marshal(Envelope{
+		ID:        due.EventID,
+		Type:      due.Type,
+		CreatedAt: due.CreatedAt,
+		Data:      json.RawMessage(due.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, due.Type)
+	req.Header.Set(DeliveryHeader, due.EventID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(ctx context.Context, attempt *postgres.WebhookAttempt, outcome postgres.DeliveryOutcome) {
+	if err := d.storage.RecordDeliveryAttempt(ctx, attempt, outcome); err != nil {
+		d.log.Error("record webhook attempt", slog.String("error", err.Error()))
+	}
+}
+
+// backoff: 30s, 1m, 2m, ... но не больше часа.
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,294 @@
+package events
+
+import (
+	"ahub/internal/config"
+	"ahub/storage/postgres"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "whsec-test"
+
+// fakeDeliveries — хранилище доставок в памяти: одна доставка на каждую пару событие/подписчик.
+type fakeDeliveries struct {
+	mu         sync.Mutex
+	deliveries []*fakeDelivery
+	attempts   []postgres.WebhookAttempt
+	claims     []int
+}
+
+type fakeDelivery struct {
+	due           postgres.DueDelivery
+	nextAttemptAt time.Time
+	delivered     bool
+	gaveUp        bool
+}
+
+func (f *fakeDeliveries) add(due postgres.DueDelivery) {
+	f.deliveries = append(f.deliveries, &fakeDelivery{due: due})
+}
+
+func (f *fakeDeliveries) FanOutOutboxEvents(ctx context.Context, limit int, subscribers func(string) []string) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeDeliveries) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]postgres.DueDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.claims = append(f.claims, limit)
+
+	var due []postgres.DueDelivery
+	for _, d := range f.deliveries {
+		if len(due) == limit {
+			break
+		}
+		if d.delivered || d.gaveUp || d.nextAttemptAt.After(now) {
+			continue
+		}
+		d.nextAttemptAt = now.Add(lease)
+		due = append(due, d.due)
+	}
+
+	return due, nil
+}
+
+func (f *fakeDeliveries) RecordDeliveryAttempt(ctx context.Context, attempt *postgres.WebhookAttempt, outcome postgres.DeliveryOutcome) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attempts = append(f.attempts, *attempt)
+
+	for _, d := range f.deliveries {
+		if d.due.EventID != attempt.EventID || d.due.Subscriber != attempt.Subscriber {
+			continue
+		}
+		d.due.Attempts++
+		switch {
+		case outcome.Delivered:
+			d.delivered = true
+		case outcome.GaveUp:
+			d.gaveUp = true
+		default:
+			d.nextAttemptAt = outcome.NextAttemptAt
+		}
+	}
+
+	return nil
+}
+
+// receiver — подписчик на httptest-сервере; отвечает status и запоминает полученные запросы.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+	status := r.status
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func newTestDispatcher(t *testing.T, status int, maxAttempts int) (*Dispatcher, *fakeDeliveries, *receiver) {
+	t.Helper()
+
+	rcv := &receiver{status: status}
+	srv := httptest.NewServer(rcv)
+	t.Cleanup(srv.Close)
+
+	store := &fakeDeliveries{}
+	d := newDispatcher(store, config.WebhooksConfig{
+		PollInterval: "1s",
+		Timeout:      "5s",
+		MaxAttempts:  maxAttempts,
+		BatchSize:    10,
+		Subscribers: []config.WebhookSubscriber{
+			{Name: "crm", URL: srv.URL, Secret: testSecret},
+		},
+	}, srv.Client(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	return d, store, rcv
+}
+
+func testDelivery(eventID string) postgres.DueDelivery {
+	return postgres.DueDelivery{
+		EventID:    eventID,
+		Subscriber: "crm",
+		Type:       UserRegistered,
+		Payload:    `{"user_id":"u1"}`,
+		CreatedAt:  time.Now().Add(-time.Minute).UTC(),
+	}
+}
+
+func TestDispatcherDeliversSignedEnvelope(t *testing.T) {
+	d, store, rcv := newTestDispatcher(t, http.StatusNoContent, 3)
+	store.add(testDelivery("e1"))
+
+	d.Tick(context.Background())
+
+	if len(rcv.requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(rcv.requests))
+	}
+	req := rcv.requests[0]
+
+	if err := VerifySignature(testSecret, req.header.Get(SignatureHeader), req.body, time.Minute, time.Now()); err != nil {
+		t.Fatalf("verify signature: %v", err)
+	}
+	if err := VerifySignature("other-secret", req.header.Get(SignatureHeader), req.body, time.Minute, time.Now()); err == nil {
+		t.Fatal("signature verified with a wrong secret")
+	}
+	if got := req.header.Get(EventHeader); got != UserRegistered {
+		t.Fatalf("event header = %q, want %q", got, UserRegistered)
+	}
+	if got := req.header.Get(DeliveryHeader); got != "e1" {
+		t.Fatalf("delivery header = %q, want e1", got)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(req.body, &env); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	if env.ID != "e1" || env.Type != UserRegistered || string(env.Data) != `{"user_id":"u1"}` {
+		t.Fatalf("unexpected envelope: %+v", env)
+	}
+
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+	a := store.attempts[0]
+	if a.Attempt != 1 || a.StatusCode != http.StatusNoContent || a.Error != "" || a.URL == "" {
+		t.Fatalf("unexpected attempt: %+v", a)
+	}
+	if !store.deliveries[0].delivered {
+		t.Fatal("delivery is not marked as delivered")
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	d, store, rcv := newTestDispatcher(t, http.StatusInternalServerError, 5)
+	store.add(testDelivery("e1"))
+
+	before := time.Now()
+	d.Tick(context.Background())
+
+	// повтор назначен на будущее, поэтому второй проход ничего не отправляет
+	d.Tick(context.Background())
+
+	if len(rcv.requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(rcv.requests))
+	}
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+	a := store.attempts[0]
+	if a.StatusCode != http.StatusInternalServerError || a.Error == "" {
+		t.Fatalf("unexpected attempt: %+v", a)
+	}
+
+	delivery := store.deliveries[0]
+	if delivery.delivered || delivery.gaveUp {
+		t.Fatalf("delivery finished after a failed attempt: %+v", delivery)
+	}
+	if next := delivery.nextAttemptAt; next.Before(before.Add(baseBackoff)) || next.After(time.Now().Add(baseBackoff)) {
+		t.Fatalf("next attempt at %v, want about %v from now", next, baseBackoff)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		20: time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	d, store, rcv := newTestDispatcher(t, http.StatusBadGateway, 3)
+	store.add(testDelivery("e1"))
+
+	for range 3 {
+		d.Tick(context.Background())
+		// пропускаем ожидание backoff
+		store.deliveries[0].nextAttemptAt = time.Time{}
+	}
+	d.Tick(context.Background())
+
+	if len(rcv.requests) != 3 {
+		t.Fatalf("receiver got %d requests, want 3", len(rcv.requests))
+	}
+	if len(store.attempts) != 3 {
+		t.Fatalf("recorded %d attempts, want 3", len(store.attempts))
+	}
+	for i, a := range store.attempts {
+		if a.Attempt != i+1 {
+			t.Fatalf("attempt %d recorded as %d", i+1, a.Attempt)
+		}
+	}
+	if !store.deliveries[0].gaveUp {
+		t.Fatal("delivery is not abandoned after max attempts")
+	}
+}
+
+func TestDispatcherGivesUpOnUnknownSubscriber(t *testing.T) {
+	d, store, rcv := newTestDispatcher(t, http.StatusOK, 3)
+	due := testDelivery("e1")
+	due.Subscriber = "removed"
+	store.add(due)
+
+	d.Tick(context.Background())
+
+	if len(rcv.requests) != 0 {
+		t.Fatalf("receiver got %d requests, want 0", len(rcv.requests))
+	}
+	if len(store.attempts) != 1 || store.attempts[0].Error == "" {
+		t.Fatalf("unexpected attempts: %+v", store.attempts)
+	}
+	if !store.deliveries[0].gaveUp {
+		t.Fatal("delivery to an unknown subscriber is not abandoned")
+	}
+}
+
+// Доставки забираются по одной, чтобы аренда покрывала только текущую отправку.
+func TestDispatcherClaimsOneDeliveryAtATime(t *testing.T) {
+	d, store, rcv := newTestDispatcher(t, http.StatusOK, 3)
+	for _, id := range []string{"e1", "e2", "e3"} {
+		store.add(testDelivery(id))
+	}
+
+	d.Tick(context.Background())
+
+	if len(rcv.requests) != 3 {
+		t.Fatalf("receiver got %d requests, want 3", len(rcv.requests))
+	}
+	for _, limit := range store.claims {
+		if limit != 1 {
+			t.Fatalf("claimed %d deliveries at once, want 1", limit)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"ahub/storage/postgres"
+	"encoding/json"
+	"time"
+)
+
+const (
+	UserRegistered = "user.registered"
+	UserDeleted    = "user.deleted"
+	SessionRevoked = "session.revoked"
+)
+
+type UserRegisteredData struct {
+	UserID string `json:"user_id"`
+}
+
+type UserDeletedData struct {
+	UserID     string    `json:"user_id"`
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+type SessionRevokedData struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Reason    string `json:"reason"`
+}
+
+// Envelope — тело запроса, которое получает подписчик.
+type Envelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// New готовит событие для записи в outbox.
+func New(eventType string, data any) (*postgres.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgres.OutboxEvent{Type: eventType, Payload: string(payload)}, nil
+}
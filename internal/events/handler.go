@@ -0,0 +1,80 @@
+package events
+
+import (
+	"ahub/storage/postgres"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AttemptsQuery struct {
+	EventID string `form:"event_id" binding:"omitempty,uuid"`
+	Limit   int    `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+type Attempt struct {
+	ID         string    `json:"id"`
+	EventID    string    `json:"event_id"`
+	URL        string    `json:"url"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Handler struct {
+	storage *postgres.Storage
+}
+
+func NewHandler(storage *postgres.Storage) *Handler {
+	return &Handler{storage: storage}
+}
+
+// ListAttempts отдаёт журнал доставки подписчика.
+func (h *Handler) ListAttempts(c *gin.Context) {
+	var req AttemptsQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := h.storage.ListWebhookAttempts(ctx, c.Param("subscriber"), req.EventID, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	attempts := make([]Attempt, 0, len(rows))
+	for _, r := range rows {
+		attempts = append(attempts, Attempt{
+			ID:         r.ID,
+			EventID:    r.EventID,
+			URL:        r.URL,
+			Attempt:    r.Attempt,
+			StatusCode: r.StatusCode,
+			Error:      r.Error,
+			DurationMS: r.DurationMS,
+			CreatedAt:  r.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attempts": attempts})
+}
+
+// RegisterRoutes: middleware — аутентификация и проверка прав административного API.
+func RegisterRoutes(r *gin.Engine, h *Handler, middleware ...gin.HandlerFunc) {
+	admin := r.Group("/admin/webhooks")
+	admin.Use(middleware...)
+	{
+		admin.GET("/:subscriber/attempts", h.ListAttempts)
+	}
+}
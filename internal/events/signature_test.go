@@ -0,0 +1,25 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifySignatureRejectsTamperedAndStale(t *testing.T) {
+	body := []byte(`{"id":"e1"}`)
+	now := time.Now()
+	header := Sign(testSecret, now, body)
+
+	if err := VerifySignature(testSecret, header, body, time.Minute, now); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := VerifySignature(testSecret, header, []byte(`{"id":"e2"}`), time.Minute, now); err == nil {
+		t.Fatal("tampered body verified")
+	}
+	if err := VerifySignature(testSecret, header, body, time.Minute, now.Add(2*time.Minute)); err == nil {
+		t.Fatal("stale signature verified")
+	}
+	if err := VerifySignature(testSecret, "v1=deadbeef", body, time.Minute, now); err == nil {
+		t.Fatal("signature without timestamp verified")
+	}
+}
@@ -0,0 +1,71 @@
+package identifier
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+type Kind string
+
+const (
+	Email Kind = "email"
+	Phone Kind = "phone"
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email")
+	ErrInvalidPhone = errors.New("invalid phone")
+
+	emailRegex = regexp.MustCompile(`^[\w._%+\-]+@[\w.\-]+\.[a-zA-Z]{2,}$`)
+)
+
+// Identifier — логин в каноническом виде: email в нижнем регистре или телефон в E.164.
+type Identifier struct {
+	Kind  Kind
+	Value string
+}
+
+type Normalizer struct {
+	defaultRegion string
+}
+
+// NewNormalizer принимает регион (ISO 3166-1 alpha-2), по которому разбираются номера без кода страны.
+func NewNormalizer(defaultRegion string) *Normalizer {
+	return &Normalizer{defaultRegion: strings.ToUpper(defaultRegion)}
+}
+
+// Normalize определяет тип логина: всё, что содержит "@", считается email.
+func (n *Normalizer) Normalize(raw string) (Identifier, error) {
+	if strings.Contains(raw, "@") {
+		email, err := n.Email(raw)
+		if err != nil {
+			return Identifier{}, err
+		}
+		return Identifier{Kind: Email, Value: email}, nil
+	}
+
+	phone, err := n.Phone(raw)
+	if err != nil {
+		return Identifier{}, err
+	}
+	return Identifier{Kind: Phone, Value: phone}, nil
+}
+
+func (n *Normalizer) Email(raw string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(raw))
+	if !emailRegex.MatchString(email) {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+func (n *Normalizer) Phone(raw string) (string, error) {
+	num, err := phonenumbers.Parse(strings.TrimSpace(raw), n.defaultRegion)
+	if err != nil || !phonenumbers.IsValidNumber(num) {
+		return "", ErrInvalidPhone
+	}
+	return phonenumbers.Format(num, phonenumbers.E164), nil
+}
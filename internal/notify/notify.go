@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Sender доставляет короткое сообщение на email или телефон.
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// Router выбирает канал по адресу: email уходит в Email, всё остальное в SMS.
+type Router struct {
+	Email Sender
+	SMS   Sender
+}
+
+func (r *Router) Send(ctx context.Context, to, subject, body string) error {
+	if strings.Contains(to, "@") {
+		return r.Email.Send(ctx, to, subject, body)
+	}
+	return r.SMS.Send(ctx, to, subject, body)
+}
+
+// LogSender пишет сообщения в лог вместо реальной отправки (локальная разработка, нет провайдера).
+type LogSender struct {
+	Log *slog.Logger
+}
+
+func (l *LogSender) Send(_ context.Context, to, subject, body string) error {
+	l.Log.Info("notification",
+		slog.String("to", to),
+		slog.String("subject", subject),
+		slog.String("body", body),
+	)
+	return nil
+}
@@ -0,0 +1,166 @@
+package password
+
+import (
+	"ahub/internal/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// PasswordHasher — способ хранения паролей. Pool выполняет его на ограниченном числе горутин.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify сообщает, подходит ли пароль, и нужно ли пересчитать хеш с текущими параметрами.
+	Verify(password, encoded string) (match bool, rehash bool, err error)
+}
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Hasher хранит пароли в формате PHC ($argon2id$v=19$m=...,t=...,p=...$salt$hash).
+// Старые bcrypt-хеши ($2a$/$2b$/$2y$) по-прежнему проверяются, но всегда требуют перехеширования.
+type Hasher struct {
+	params Argon2Params
+}
+
+var _ PasswordHasher = (*Hasher)(nil)
+
+// NewHasher проверяет параметры при запуске: с нулевыми iterations или parallelism
+// argon2.IDKey паникует на первом же входе.
+func NewHasher(cfg config.Argon2Config) (*Hasher, error) {
+	params := Argon2Params{
+		Memory:      cfg.Memory,
+		Iterations:  cfg.Iterations,
+		Parallelism: cfg.Parallelism,
+		SaltLength:  cfg.SaltLength,
+		KeyLength:   cfg.KeyLength,
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Hasher{params: params}, nil
+}
+
+// validate: ограничения RFC 9106 (m >= 8*p) плюс минимальные длины соли и ключа.
+func (p Argon2Params) validate() error {
+	switch {
+	case p.Iterations < 1:
+		return fmt.Errorf("argon2 iterations must be at least 1, got %d", p.Iterations)
+	case p.Parallelism < 1:
+		return fmt.Errorf("argon2 parallelism must be at least 1, got %d", p.Parallelism)
+	case p.Memory < 8*uint32(p.Parallelism):
+		return fmt.Errorf("argon2 memory must be at least 8*parallelism KiB, got %d", p.Memory)
+	case p.SaltLength < 8:
+		return fmt.Errorf("argon2 salt length must be at least 8 bytes, got %d", p.SaltLength)
+	case p.KeyLength < 16:
+		return fmt.Errorf("argon2 key length must be at least 16 bytes, got %d", p.KeyLength)
+	}
+	return nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Hasher) Verify(password, encoded string) (match bool, rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.verifyArgon2id(password, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	default:
+		return false, false, ErrUnknownHash
+	}
+}
+
+func (h *Hasher) verifyArgon2id(password, encoded string) (bool, bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+
+	rehash := params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+
+	return true, rehash, nil
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 hash: %w", err)
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	// параметры из базы проверяются так же, как из конфига: испорченная строка не должна ронять процесс
+	if err := p.validate(); err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	return p, salt, key, nil
+}
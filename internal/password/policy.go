@@ -0,0 +1,112 @@
+package password
+
+import (
+	"ahub/internal/config"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError перечисляет все нарушенные правила, а не только первое.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Message)
+	}
+	return "password does not meet policy: " + strings.Join(msgs, "; ")
+}
+
+type Policy struct {
+	cfg      config.PasswordConfig
+	breached *BreachedList
+}
+
+// NewPolicy: breached может быть nil, тогда проверка по утёкшим паролям отключена.
+func NewPolicy(cfg config.PasswordConfig, breached *BreachedList) *Policy {
+	return &Policy{cfg: cfg, breached: breached}
+}
+
+// Check проверяет пароль; personal — имя, фамилия, логин и т.п., которые нельзя включать в пароль.
+func (p *Policy) Check(password string, personal ...string) error {
+	var violations []Violation
+
+	add := func(rule, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if n := len([]rune(password)); n < p.cfg.MinLength {
+		add("min_length", "must be at least %d characters", p.cfg.MinLength)
+	}
+	// максимум считается в байтах: он ограничивает объём работы хешера
+	if p.cfg.MaxLength > 0 && len(password) > p.cfg.MaxLength {
+		add("max_length", "must be at most %d bytes", p.cfg.MaxLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	if p.cfg.RequireLower && !lower {
+		add("lower", "must contain a lowercase letter")
+	}
+	if p.cfg.RequireUpper && !upper {
+		add("upper", "must contain an uppercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		add("digit", "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		add("symbol", "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	for _, s := range personal {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if local, _, ok := strings.Cut(s, "@"); ok {
+			s = local
+		}
+		if len([]rune(s)) >= 3 && strings.Contains(lowered, s) {
+			add("personal", "must not contain your name or login")
+			break
+		}
+	}
+
+	if score := Strength(password, personal...); score < p.cfg.MinScore {
+		add("strength", "is too easy to guess (strength %d of 4, need %d)", score, p.cfg.MinScore)
+	}
+
+	if len(violations) == 0 && p.breached != nil {
+		found, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if found {
+			add("breached", "has appeared in a data breach, choose another one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package password
+
+import (
+	"ahub/internal/config"
+	"context"
+	"expvar"
+	"time"
+)
+
+// BusyError возвращается, когда все воркеры заняты и очередь заполнена.
+type BusyError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return "password hashing capacity exhausted, retry later"
+}
+
+var (
+	poolStats = expvar.NewMap("password_hash_pool")
+
+	// верхние границы корзин времени ожидания в очереди
+	waitBuckets = []time.Duration{
+		time.Millisecond,
+		10 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		500 * time.Millisecond,
+		time.Second,
+	}
+)
+
+// Pool ограничивает число одновременных вычислений хешей: Argon2id и bcrypt намеренно
+// дорогие, и без ограничения всплеск логинов занимает все CPU.
+type Pool struct {
+	hasher     PasswordHasher
+	workers    chan struct{}
+	admitted   chan struct{}
+	retryAfter time.Duration
+}
+
+func NewPool(hasher PasswordHasher, cfg config.HashPoolConfig) *Pool {
+	return &Pool{
+		hasher:     hasher,
+		workers:    make(chan struct{}, cfg.Concurrency),
+		admitted:   make(chan struct{}, cfg.Concurrency+cfg.QueueDepth),
+		retryAfter: cfg.RetryAfterDuration(),
+	}
+}
+
+func (p *Pool) Hash(ctx context.Context, password string) (string, error) {
+	var (
+		hash string
+		err  error
+	)
+
+	if perr := p.run(ctx, func() { hash, err = p.hasher.Hash(password) }); perr != nil {
+		return "", perr
+	}
+
+	return hash, err
+}
+
+func (p *Pool) Verify(ctx context.Context, password, encoded string) (match bool, rehash bool, err error) {
+	if perr := p.run(ctx, func() { match, rehash, err = p.hasher.Verify(password, encoded) }); perr != nil {
+		return false, false, perr
+	}
+
+	return match, rehash, err
+}
+
+func (p *Pool) run(ctx context.Context, fn func()) error {
+	select {
+	case p.admitted <- struct{}{}:
+	default:
+		poolStats.Add("rejected", 1)
+		return &BusyError{RetryAfter: p.retryAfter}
+	}
+	defer func() { <-p.admitted }()
+
+	poolStats.Add("queued", 1)
+	start := time.Now()
+
+	select {
+	case p.workers <- struct{}{}:
+		poolStats.Add("queued", -1)
+	case <-ctx.Done():
+		poolStats.Add("queued", -1)
+		poolStats.Add("expired", 1)
+		return ctx.Err()
+	}
+	defer func() { <-p.workers }()
+
+	observeWait(time.Since(start))
+
+	poolStats.Add("in_flight", 1)
+	defer poolStats.Add("in_flight", -1)
+
+	fn()
+
+	return nil
+}
+
+func observeWait(wait time.Duration) {
+	poolStats.Add("wait_count", 1)
+	poolStats.Add("wait_ns_total", wait.Nanoseconds())
+
+	for _, b := range waitBuckets {
+		if wait <= b {
+			poolStats.Add("wait_le_"+b.String(), 1)
+			return
+		}
+	}
+	poolStats.Add("wait_le_inf", 1)
+}
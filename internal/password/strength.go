@@ -0,0 +1,161 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonFragments — самые частые основы паролей; встреченная подстрока почти не добавляет стойкости.
+var commonFragments = []string{
+	"password", "passw0rd", "qwerty", "asdfgh", "zxcvbn", "letmein", "welcome",
+	"admin", "login", "iloveyou", "monkey", "dragon", "football", "baseball",
+	"master", "sunshine", "princess", "shadow", "superman", "trustno1",
+	"123456", "111111", "abc123", "qwe123", "1q2w3e", "parol", "privet",
+}
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"йцукенгшщзхъ",
+	"фывапролджэ",
+	"ячсмитьбю",
+}
+
+// Strength оценивает пароль по шкале zxcvbn от 0 (угадывается мгновенно) до 4 (очень стойкий).
+// Это упрощённая модель: энтропия алфавита, из которой вычитаются повторы, последовательности,
+// соседние клавиши, частые основы паролей и персональные данные.
+func Strength(password string, personal ...string) int {
+	runes := []rune(strings.ToLower(password))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(alphabetSize(password)))
+
+	// помечаем символы, которые входят в предсказуемые фрагменты
+	cheap := make([]bool, len(runes))
+	lowered := string(runes)
+
+	markFragments := func(fragment string) {
+		if len([]rune(fragment)) < 3 {
+			return
+		}
+		for start := 0; ; {
+			idx := strings.Index(lowered[start:], fragment)
+			if idx < 0 {
+				return
+			}
+			from := len([]rune(lowered[:start+idx]))
+			for i := from; i < from+len([]rune(fragment)); i++ {
+				cheap[i] = true
+			}
+			start += idx + len(fragment)
+		}
+	}
+
+	for _, f := range commonFragments {
+		markFragments(f)
+	}
+	for _, p := range personal {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if local, _, ok := strings.Cut(p, "@"); ok {
+			p = local
+		}
+		markFragments(p)
+	}
+
+	bits := 0.0
+	fragmentBits := math.Log2(float64(len(commonFragments)))
+	inFragment := false
+
+	for i, r := range runes {
+		if cheap[i] {
+			// весь фрагмент стоит как выбор одного слова из словаря
+			if !inFragment {
+				bits += fragmentBits
+			}
+			inFragment = true
+			continue
+		}
+		inFragment = false
+
+		if i > 0 && (r == runes[i-1] || r == runes[i-1]+1 || r == runes[i-1]-1 || adjacentKeys(runes[i-1], r)) {
+			bits += 0.5
+			continue
+		}
+
+		bits += perChar
+	}
+
+	guessesLog10 := bits * math.Log10(2)
+
+	switch {
+	case guessesLog10 < 3:
+		return 0
+	case guessesLog10 < 6:
+		return 1
+	case guessesLog10 < 8:
+		return 2
+	case guessesLog10 < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func alphabetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 66
+	}
+	if size < 2 {
+		size = 2
+	}
+	return size
+}
+
+func adjacentKeys(a, b rune) bool {
+	for _, row := range keyboardRows {
+		keys := []rune(row)
+		for i, k := range keys {
+			if k != a {
+				continue
+			}
+			if (i > 0 && keys[i-1] == b) || (i+1 < len(keys) && keys[i+1] == b) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Result — решение GCRA для одного запроса.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	// ResetAfter — через сколько лимит полностью восстановится
+	ResetAfter time.Duration
+}
+
+type rate struct {
+	emission  time.Duration // интервал между запросами при равномерном потоке
+	tolerance time.Duration // сколько «вперёд» можно занять: emission * burst
+	burst     int
+}
+
+// gcraScript хранит в ключе теоретическое время прибытия (TAT) в микросекундах.
+// Время берётся из Redis, чтобы у всех инстансов были одинаковые часы.
+var gcraScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local new_tat = tat + emission
+local allow_at = new_tat - tolerance
+
+if allow_at > now then
+	return {0, allow_at - now, tat - now}
+end
+
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil((new_tat - now) / 1000))
+return {1, 0, new_tat - now}
+`)
+
+func redisAllow(ctx context.Context, rdb *redis.Client, key string, r rate) (Result, error) {
+	res, err := gcraScript.Run(ctx, rdb, []string{key},
+		r.emission.Microseconds(), r.tolerance.Microseconds(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result(res[0] == 1, time.Duration(res[1])*time.Microsecond, time.Duration(res[2])*time.Microsecond, r), nil
+}
+
+func result(allowed bool, retryAfter, resetAfter time.Duration, r rate) Result {
+	remaining := 0
+	if r.emission > 0 {
+		remaining = int((r.tolerance - resetAfter) / r.emission)
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining > r.burst {
+		remaining = r.burst
+	}
+
+	return Result{
+		Allowed:    allowed,
+		Remaining:  remaining,
+		RetryAfter: retryAfter,
+		ResetAfter: resetAfter,
+	}
+}
+
+// memoryStore — тот же GCRA в памяти процесса; используется, пока Redis недоступен.
+// Лимит в этом режиме считается на инстанс, а не на весь кластер.
+type memoryStore struct {
+	mu    sync.Mutex
+	tats  map[string]time.Time
+	calls int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{tats: make(map[string]time.Time)}
+}
+
+func (m *memoryStore) allow(key string, r rate) Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	m.calls++
+	if m.calls%1000 == 0 {
+		for k, tat := range m.tats {
+			if tat.Before(now) {
+				delete(m.tats, k)
+			}
+		}
+	}
+
+	tat, ok := m.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	newTAT := tat.Add(r.emission)
+	allowAt := newTAT.Add(-r.tolerance)
+
+	if allowAt.After(now) {
+		return result(false, allowAt.Sub(now), tat.Sub(now), r)
+	}
+
+	m.tats[key] = newTAT
+	return result(true, 0, newTAT.Sub(now), r)
+}
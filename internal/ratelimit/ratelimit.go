@@ -0,0 +1,228 @@
+package ratelimit
+
+import (
+	"ahub/internal/config"
+	"ahub/internal/identifier"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	KeyIP    = "ip"
+	KeyLogin = "login"
+	KeyUser  = "user"
+)
+
+// maxLoginBody — сколько тела читается ради поля login; запросы входа намного меньше.
+const maxLoginBody = 1 << 20
+
+var (
+	ErrBodyTooLarge   = errors.New("request body too large")
+	ErrBodyUnreadable = errors.New("request body could not be read")
+)
+
+type policy struct {
+	name   string
+	method string
+	path   string
+	key    string
+	limit  int
+	rate   rate
+	period time.Duration
+}
+
+// Limiter применяет политики из конфига к маршрутам по методу и шаблону пути (c.FullPath()).
+type Limiter struct {
+	rdb      *redis.Client
+	ids      *identifier.Normalizer
+	memory   *memoryStore
+	policies []policy
+	enabled  bool
+	log      *slog.Logger
+}
+
+// ids приводит логин из тела к тому же виду, что и при входе, чтобы "User@Mail.ru" и
+// "user@mail.ru" или разные записи одного номера делили один лимит.
+func New(rdb *redis.Client, ids *identifier.Normalizer, cfg config.RateLimitConfig, log *slog.Logger) *Limiter {
+	l := &Limiter{
+		rdb:     rdb,
+		ids:     ids,
+		memory:  newMemoryStore(),
+		enabled: cfg.Enabled,
+		log:     log,
+	}
+
+	for _, p := range cfg.Policies {
+		period := p.PeriodDuration()
+
+		if p.Limit <= 0 {
+			log.Warn("rate limit policy without limit is ignored", slog.String("policy", p.Name))
+			continue
+		}
+
+		burst := p.Burst
+		if burst <= 0 {
+			burst = p.Limit
+		}
+
+		emission := period / time.Duration(p.Limit)
+
+		l.policies = append(l.policies, policy{
+			name:   p.Name,
+			method: strings.ToUpper(p.Method),
+			path:   p.Path,
+			key:    p.Key,
+			limit:  p.Limit,
+			period: period,
+			rate: rate{
+				emission:  emission,
+				tolerance: emission * time.Duration(burst),
+				burst:     burst,
+			},
+		})
+	}
+
+	return l
+}
+
+// Middleware нужно ставить после AuthMiddleware там, где есть политики с ключом user.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.enabled {
+			c.Next()
+			return
+		}
+
+		var strictest *Result
+		var strictestPolicy policy
+
+		for _, p := range l.policies {
+			if p.path != c.FullPath() || (p.method != "" && p.method != c.Request.Method) {
+				continue
+			}
+
+			res, err := l.allow(c, p)
+			if err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, ErrBodyTooLarge) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+				return
+			}
+
+			if !res.Allowed {
+				setHeaders(c, p, res)
+				c.Header("Retry-After", seconds(res.RetryAfter))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+				return
+			}
+
+			if strictest == nil || res.Remaining < strictest.Remaining {
+				strictest = &res
+				strictestPolicy = p
+			}
+		}
+
+		if strictest != nil {
+			setHeaders(c, strictestPolicy, *strictest)
+		}
+
+		c.Next()
+	}
+}
+
+func (l *Limiter) allow(c *gin.Context, p policy) (Result, error) {
+	subject, err := l.subject(c, p.key)
+	if err != nil {
+		return Result{}, err
+	}
+	key := "ratelimit:" + p.name + ":" + subject
+
+	res, err := redisAllow(c.Request.Context(), l.rdb, key, p.rate)
+	if err == nil {
+		return res, nil
+	}
+
+	l.log.Warn("rate limit: redis unavailable, using in-memory limiter",
+		slog.String("policy", p.name),
+		slog.String("error", err.Error()),
+	)
+
+	return l.memory.allow(key, p.rate), nil
+}
+
+// subject возвращает то, по чему считается лимит; если нужного значения нет, считаем по IP.
+func (l *Limiter) subject(c *gin.Context, key string) (string, error) {
+	switch key {
+	case KeyUser:
+		if id := c.GetString("user_id"); id != "" {
+			return "user:" + id, nil
+		}
+	case KeyLogin:
+		login, err := l.peekLogin(c)
+		if err != nil {
+			return "", err
+		}
+		if login != "" {
+			return "login:" + login, nil
+		}
+	}
+
+	return "ip:" + c.ClientIP(), nil
+}
+
+// peekLogin читает поле "login" из JSON-тела и возвращает тело обратно в запрос. Тело больше
+// maxLoginBody или недочитанное из-за ошибки отклоняется: обрезанное тело сломало бы разбор
+// запроса в обработчике, а лимит незаметно считался бы по IP.
+func (l *Limiter) peekLogin(c *gin.Context) (string, error) {
+	if c.Request.Body == nil {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxLoginBody+1))
+	if err != nil {
+		return "", ErrBodyUnreadable
+	}
+	if len(body) > maxLoginBody {
+		return "", ErrBodyTooLarge
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Login string `json:"login"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", nil
+	}
+
+	// некорректный логин вход всё равно отклонит, но попытки считаются по его исходному виду
+	id, err := l.ids.Normalize(payload.Login)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(payload.Login)), nil
+	}
+
+	return id.Value, nil
+}
+
+func setHeaders(c *gin.Context, p policy, res Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(p.rate.burst))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", seconds(res.ResetAfter))
+	c.Header("RateLimit-Policy", strconv.Itoa(p.limit)+";w="+seconds(p.period)+";burst="+strconv.Itoa(p.rate.burst))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,58 @@
+package requestctx
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type ctxKey struct{}
+
+// Info — сведения о клиенте, которые нужны сервисам ниже HTTP-слоя.
+type Info struct {
+	IP        string
+	UserAgent string
+	RequestID string
+	// Impersonator — администратор, действующий по токену имперсонации; пусто для обычных запросов.
+	Impersonator string
+}
+
+func With(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// WithImpersonator дополняет Info из ctx администратором, который действует от имени пользователя.
+func WithImpersonator(ctx context.Context, impersonator string) context.Context {
+	info := From(ctx)
+	info.Impersonator = impersonator
+	return With(ctx, info)
+}
+
+func From(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	return info
+}
+
+// Middleware кладёт Info в контекст запроса, чтобы он доходил до сервисов вместе с ctx.
+// X-Request-ID берётся от прокси, если он есть, иначе генерируется, и возвращается в ответе.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := With(c.Request.Context(), Info{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			RequestID: requestID,
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var ErrMalformed = errors.New("malformed ciphertext")
+
+// Box шифрует небольшие секреты (например, TOTP-ключи) перед записью в БД: AES-256-GCM,
+// результат — base64(nonce || ciphertext).
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New принимает ключ в base64 (32 байта после декодирования).
+func New(keyBase64 string) (*Box, error) {
+	key, err := base64.StdEncoding.DecodeString(keyBase64)
+	if err != nil {
+		return nil, fmt.Errorf("decode encryption key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+func (b *Box) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *Box) Open(encoded string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrMalformed
+	}
+
+	return b.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
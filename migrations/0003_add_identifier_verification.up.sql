@@ -0,0 +1,7 @@
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMPTZ,
+    ADD COLUMN phone_verified_at TIMESTAMPTZ;
+
+-- существующие пользователи подтверждали логин кодом при регистрации
+UPDATE users SET email_verified_at = created_at WHERE email IS NOT NULL;
+UPDATE users SET phone_verified_at = created_at WHERE phone IS NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMPTZ,
+    ADD COLUMN purge_after TIMESTAMPTZ;
+
+CREATE INDEX users_purge_after_idx ON users (purge_after) WHERE purge_after IS NOT NULL;
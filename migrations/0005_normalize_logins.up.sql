@@ -0,0 +1,20 @@
+-- адреса, отличающиеся только регистром: подтверждённым остаётся самый старый аккаунт,
+-- у остальных подтверждение снимается — адрес сохраняется, но входить по нему нельзя,
+-- пока конфликт не разберёт поддержка
+UPDATE users u SET email_verified_at = NULL
+FROM (
+    SELECT id, row_number() OVER (
+        PARTITION BY lower(btrim(email))
+        ORDER BY email_verified_at IS NULL, created_at, id
+    ) AS rn
+    FROM users
+    WHERE email IS NOT NULL
+) d
+WHERE u.id = d.id AND d.rn > 1;
+
+-- уникальность теперь проверяет индекс по lower(email) среди подтверждённых адресов
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+
+UPDATE users SET email = lower(btrim(email)) WHERE email IS NOT NULL;
+
+CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email)) WHERE email_verified_at IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS amr,
+    DROP COLUMN IF EXISTS auth_time;
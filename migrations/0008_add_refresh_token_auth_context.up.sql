@@ -0,0 +1,6 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN auth_time TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN amr TEXT NOT NULL DEFAULT '';
+
+-- для уже выданных токенов считаем моментом входа время создания токена
+UPDATE refresh_tokens SET auth_time = created_at;
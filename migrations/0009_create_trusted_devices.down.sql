@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS refresh_tokens_id_key;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS id;
+
+DROP TABLE IF EXISTS trusted_devices;
@@ -0,0 +1,16 @@
+CREATE TABLE trusted_devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    fingerprint TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX trusted_devices_user_id_idx ON trusted_devices (user_id);
+
+-- идентификатор сессии для API управления сессиями (сам токен наружу не отдаётся)
+ALTER TABLE refresh_tokens ADD COLUMN id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX refresh_tokens_id_key ON refresh_tokens (id);
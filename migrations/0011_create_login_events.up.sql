@@ -0,0 +1,17 @@
+CREATE TABLE login_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    method TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    failure_reason TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    -- устройство успешного входа: по нему определяется, знакомо ли устройство
+    device_key TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX login_events_user_created_idx ON login_events (user_id, created_at DESC, id DESC);
+CREATE INDEX login_events_created_idx ON login_events (created_at);
+CREATE INDEX login_events_user_device_idx ON login_events (user_id, device_key) WHERE event = 'login' AND success;
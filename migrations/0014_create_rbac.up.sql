@@ -0,0 +1,50 @@
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE permissions (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission TEXT NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE INDEX user_roles_role_idx ON user_roles (role);
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Default role of every registered account'),
+    ('support', 'Read-only access to accounts and the audit log'),
+    ('admin', 'Full administrative access');
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'View any account'),
+    ('users:write', 'Modify or disable any account'),
+    ('users:unlock', 'Lift sign-in lockouts'),
+    ('roles:write', 'Grant and revoke roles'),
+    ('audit:read', 'Query the audit log'),
+    ('webhooks:read', 'View webhook delivery logs');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('support', 'users:read'),
+    ('support', 'audit:read'),
+    ('admin', 'users:read'),
+    ('admin', 'users:write'),
+    ('admin', 'users:unlock'),
+    ('admin', 'roles:write'),
+    ('admin', 'audit:read'),
+    ('admin', 'webhooks:read');
+
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'user' FROM users;
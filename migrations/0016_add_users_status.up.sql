@@ -0,0 +1,12 @@
+ALTER TABLE users
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason TEXT,
+    ADD COLUMN status_changed_at TIMESTAMPTZ;
+
+UPDATE users SET status = 'pending_deletion', status_changed_at = deleted_at
+WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_status_check CHECK (status IN ('active', 'disabled', 'locked', 'pending_deletion'));
+
+CREATE INDEX users_status_idx ON users (status);
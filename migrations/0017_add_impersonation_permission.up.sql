@@ -0,0 +1,6 @@
+INSERT INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Obtain a short-lived read-only token acting as any account');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('support', 'users:impersonate'),
+    ('admin', 'users:impersonate');
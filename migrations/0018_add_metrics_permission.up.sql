@@ -0,0 +1,5 @@
+INSERT INTO permissions (name, description) VALUES
+    ('metrics:read', 'Read runtime metrics from /debug/vars');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'metrics:read');
@@ -0,0 +1,9 @@
+DROP FUNCTION IF EXISTS audit_log_prune(TIMESTAMPTZ);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS audit_checkpoints;
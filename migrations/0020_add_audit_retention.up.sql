@@ -0,0 +1,49 @@
+-- журнал по-прежнему нельзя менять, но начало цепочки старше срока хранения можно удалить
+-- через audit_log_prune; хеш последней удалённой записи остаётся в audit_checkpoints,
+-- и проверка цепочки начинается с него
+CREATE TABLE audit_checkpoints (
+    seq BIGINT PRIMARY KEY,
+    hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('ahub.audit_prune', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- удаляет записи старше before, но только с начала цепочки и всегда оставляя последнюю
+CREATE FUNCTION audit_log_prune(before TIMESTAMPTZ) RETURNS BIGINT AS $$
+DECLARE
+    boundary BIGINT;
+    last_seq BIGINT;
+    last_hash TEXT;
+    deleted BIGINT;
+BEGIN
+    SELECT min(seq) INTO boundary FROM audit_log WHERE created_at >= before;
+    IF boundary IS NULL THEN
+        SELECT max(seq) INTO boundary FROM audit_log;
+    END IF;
+
+    SELECT seq, hash INTO last_seq, last_hash
+    FROM audit_log WHERE seq < boundary
+    ORDER BY seq DESC LIMIT 1;
+
+    IF last_seq IS NULL THEN
+        RETURN 0;
+    END IF;
+
+    PERFORM set_config('ahub.audit_prune', 'on', true);
+    DELETE FROM audit_log WHERE seq <= last_seq;
+    GET DIAGNOSTICS deleted = ROW_COUNT;
+    PERFORM set_config('ahub.audit_prune', 'off', true);
+
+    INSERT INTO audit_checkpoints (seq, hash) VALUES (last_seq, last_hash);
+
+    RETURN deleted;
+END;
+$$ LANGUAGE plpgsql;
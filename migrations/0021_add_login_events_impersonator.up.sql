@@ -0,0 +1,2 @@
+-- администратор, от имени которого пользователь действовал по токену имперсонации
+ALTER TABLE login_events ADD COLUMN impersonator TEXT NOT NULL DEFAULT '';
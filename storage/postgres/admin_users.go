@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Статусы аккаунта. Locked — блокировка по соображениям безопасности (например, при подозрении
+// на взлом), disabled — отключение аккаунта администратором; pending_deletion ставится вместе с deleted_at.
+const (
+	UserStatusActive          = "active"
+	UserStatusDisabled        = "disabled"
+	UserStatusLocked          = "locked"
+	UserStatusPendingDeletion = "pending_deletion"
+)
+
+var ErrStatusTransition = errors.New("status transition is not allowed")
+
+// UserFilter: строковые поля ищут подстроку без учёта регистра; Name — по "имя фамилия".
+type UserFilter struct {
+	Email       string
+	Phone       string
+	Name        string
+	Status      string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	After       *UserCursor
+	Limit       int
+}
+
+type UserCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// ListUsers отдаёт пользователей от новых к старым, включая заблокированных и удалённых.
+func (s *Storage) ListUsers(ctx context.Context, f UserFilter) ([]User, error) {
+	query := s.db.WithContext(ctx).Model(&User{})
+
+	if f.Email != "" {
+		query = query.Where("email ILIKE ?", containsPattern(f.Email))
+	}
+	if f.Phone != "" {
+		query = query.Where("phone LIKE ?", containsPattern(f.Phone))
+	}
+	if f.Name != "" {
+		query = query.Where("(first_name || ' ' || last_name) ILIKE ?", containsPattern(f.Name))
+	}
+	if f.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		query = query.Where("created_at < ?", *f.CreatedTo)
+	}
+
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+
+	if f.After != nil {
+		query = query.Where("(created_at, id) < (?, ?)", f.After.CreatedAt, f.After.ID)
+	}
+
+	var users []User
+	if err := query.Order("created_at DESC, id DESC").Limit(f.Limit).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// SetUserStatus переводит пользователя в статус to, если текущий статус входит в from.
+// Возврат из pending_deletion отменяет запланированное удаление. Возвращает прежний статус.
+func (s *Storage) SetUserStatus(ctx context.Context, id string, from []string, to, reason string) (string, error) {
+	var user User
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			First(&user).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("get user: %w", err)
+		}
+
+		if !slices.Contains(from, user.Status) {
+			return ErrStatusTransition
+		}
+
+		now := time.Now()
+		updates := map[string]any{
+			"status":            to,
+			"status_reason":     nil,
+			"status_changed_at": now,
+		}
+		if reason != "" {
+			updates["status_reason"] = reason
+		}
+		if user.Status == UserStatusPendingDeletion {
+			updates["deleted_at"] = nil
+			updates["purge_after"] = nil
+		}
+
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return fmt.Errorf("set user status: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return user.Status, nil
+}
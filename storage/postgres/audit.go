@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditChainLock — ключ advisory-блокировки, под которой к цепочке добавляется новая запись.
+const auditChainLock = 0x61756469
+
+type AuditEntry struct {
+	Seq       int64     `gorm:"column:seq;primaryKey;autoIncrement"`
+	ID        string    `gorm:"column:id;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null"`
+	Actor     string    `gorm:"column:actor;not null"`
+	Subject   string    `gorm:"column:subject;not null"`
+	Action    string    `gorm:"column:action;not null"`
+	IP        string    `gorm:"column:ip;not null"`
+	RequestID string    `gorm:"column:request_id;not null"`
+	Metadata  string    `gorm:"column:metadata;type:jsonb;not null"`
+	PrevHash  string    `gorm:"column:prev_hash;not null"`
+	Hash      string    `gorm:"column:hash;not null"`
+}
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+type AuditFilter struct {
+	Actor   string
+	Subject string
+	Action  string
+	From    *time.Time
+	To      *time.Time
+	// BeforeSeq — курсор: отдаются записи с seq меньше этого значения
+	BeforeSeq int64
+	Limit     int
+}
+
+// AuditCheckpoint — последняя запись, удалённая по сроку хранения.
+type AuditCheckpoint struct {
+	Seq       int64     `gorm:"column:seq;primaryKey"`
+	Hash      string    `gorm:"column:hash;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;<-:false"`
+}
+
+func (AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
+
+// AppendAuditEntries дописывает записи в конец цепочки в переданном порядке. seal получает хеш
+// предыдущей записи и возвращает хеш новой; блокировка гарантирует, что у двух записей
+// не окажется общего предка. Пачка берёт блокировку один раз.
+func (s *Storage) AppendAuditEntries(ctx context.Context, entries []*AuditEntry, seal func(prevHash string, entry *AuditEntry) string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+			return fmt.Errorf("lock audit chain: %w", err)
+		}
+
+		var last AuditEntry
+		err := tx.Select("hash").Order("seq DESC").Limit(1).Take(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("get last audit entry: %w", err)
+		}
+
+		prevHash := last.Hash
+		for _, entry := range entries {
+			entry.PrevHash = prevHash
+			entry.Hash = seal(prevHash, entry)
+
+			// по одной, чтобы seq шёл в порядке цепочки
+			if err := tx.Omit("seq").Create(entry).Error; err != nil {
+				return fmt.Errorf("append audit entry: %w", err)
+			}
+			prevHash = entry.Hash
+		}
+
+		return nil
+	})
+}
+
+// PruneAuditEntries удаляет начало цепочки старше before; последняя запись остаётся всегда.
+func (s *Storage) PruneAuditEntries(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+			return fmt.Errorf("lock audit chain: %w", err)
+		}
+		return tx.Raw("SELECT audit_log_prune(?)", before).Scan(&deleted).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("prune audit entries: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// LatestAuditCheckpoint отдаёт nil, если журнал ещё ни разу не сокращался.
+func (s *Storage) LatestAuditCheckpoint(ctx context.Context) (*AuditCheckpoint, error) {
+	var cp AuditCheckpoint
+
+	err := s.db.WithContext(ctx).Order("seq DESC").Limit(1).Take(&cp).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get audit checkpoint: %w", err)
+	}
+
+	return &cp, nil
+}
+
+// ListAuditEntries отдаёт записи от новых к старым.
+func (s *Storage) ListAuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	query := s.db.WithContext(ctx).Model(&AuditEntry{})
+
+	if f.Actor != "" {
+		query = query.Where("actor = ?", f.Actor)
+	}
+	if f.Subject != "" {
+		query = query.Where("subject = ?", f.Subject)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.From != nil {
+		query = query.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("created_at < ?", *f.To)
+	}
+	if f.BeforeSeq > 0 {
+		query = query.Where("seq < ?", f.BeforeSeq)
+	}
+
+	var entries []AuditEntry
+	if err := query.Order("seq DESC").Limit(f.Limit).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("list audit entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ScanAuditEntries отдаёт записи по порядку цепочки, начиная с seq больше afterSeq.
+func (s *Storage) ScanAuditEntries(ctx context.Context, afterSeq int64, limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+
+	err := s.db.WithContext(ctx).
+		Where("seq > ?", afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&entries).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("scan audit entries: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type LoginEvent struct {
+	ID            string    `gorm:"column:id;primaryKey;default:gen_random_uuid()"`
+	UserID        string    `gorm:"column:user_id;not null"`
+	Event         string    `gorm:"column:event;not null"`
+	Method        string    `gorm:"column:method;not null"`
+	Success       bool      `gorm:"column:success;not null"`
+	FailureReason string    `gorm:"column:failure_reason;not null"`
+	IP            string    `gorm:"column:ip;not null"`
+	UserAgent     string    `gorm:"column:user_agent;not null"`
+	DeviceKey     string    `gorm:"column:device_key;not null"`
+	Impersonator  string    `gorm:"column:impersonator;not null"`
+	CreatedAt     time.Time `gorm:"column:created_at;<-:false"`
+}
+
+func (LoginEvent) TableName() string {
+	return "login_events"
+}
+
+// LoginEventCursor — позиция последнего отданного события; следующая страница начинается строго после неё.
+type LoginEventCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (s *Storage) CreateLoginEvent(ctx context.Context, event *LoginEvent) error {
+	if err := s.db.WithContext(ctx).Omit("id").Create(event).Error; err != nil {
+		return fmt.Errorf("create login event: %w", err)
+	}
+
+	return nil
+}
+
+// LoginDeviceSeen сообщает, входил ли пользователь раньше с этого устройства и был ли
+// у него хоть один успешный вход.
+func (s *Storage) LoginDeviceSeen(ctx context.Context, userID, deviceKey string) (known bool, first bool, err error) {
+	var stats struct {
+		Total int64
+		Known int64
+	}
+
+	err = s.db.WithContext(ctx).
+		Model(&LoginEvent{}).
+		Select("count(*) AS total, count(*) FILTER (WHERE device_key = ?) AS known", deviceKey).
+		Where("user_id = ? AND event = 'login' AND success", userID).
+		Scan(&stats).Error
+	if err != nil {
+		return false, false, fmt.Errorf("check login devices: %w", err)
+	}
+
+	return stats.Known > 0, stats.Total == 0, nil
+}
+
+// ListLoginEvents отдаёт события от новых к старым.
+func (s *Storage) ListLoginEvents(ctx context.Context, userID string, after *LoginEventCursor, limit int) ([]LoginEvent, error) {
+	var events []LoginEvent
+
+	query := s.db.WithContext(ctx).Where("user_id = ?", userID)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&events).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("list login events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) PurgeLoginEvents(ctx context.Context, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("created_at < ?", before).
+		Delete(&LoginEvent{})
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("purge login events: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTOTPNotFound = errors.New("totp is not set up")
+
+type UserTOTP struct {
+	UserID          string     `gorm:"column:user_id;primaryKey"`
+	SecretEncrypted string     `gorm:"column:secret_encrypted;not null"`
+	EnabledAt       *time.Time `gorm:"column:enabled_at"`
+	LastUsedStep    int64      `gorm:"column:last_used_step;not null"`
+	CreatedAt       time.Time  `gorm:"column:created_at;<-:false"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totp"
+}
+
+type RecoveryCode struct {
+	ID       string     `gorm:"column:id;primaryKey;default:gen_random_uuid()"`
+	UserID   string     `gorm:"column:user_id;not null"`
+	CodeHash string     `gorm:"column:code_hash;not null"`
+	UsedAt   *time.Time `gorm:"column:used_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+// SaveTOTPSecret начинает (или начинает заново) настройку TOTP; включается он только в EnableTOTP.
+func (s *Storage) SaveTOTPSecret(ctx context.Context, userID, secretEncrypted string) error {
+	row := UserTOTP{UserID: userID, SecretEncrypted: secretEncrypted}
+
+	err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"secret_encrypted": secretEncrypted,
+				"enabled_at":       nil,
+				"last_used_step":   0,
+			}),
+		}).
+		Create(&row).Error
+
+	if err != nil {
+		return fmt.Errorf("save totp secret: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetTOTP(ctx context.Context, userID string) (*UserTOTP, error) {
+	var row UserTOTP
+
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		First(&row).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTOTPNotFound
+		}
+		return nil, fmt.Errorf("get totp: %w", err)
+	}
+
+	return &row, nil
+}
+
+// EnableTOTP включает TOTP и заменяет резервные коды одной транзакцией.
+func (s *Storage) EnableTOTP(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&UserTOTP{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]any{
+				"enabled_at":     time.Now(),
+				"last_used_step": step,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("enable totp: %w", err)
+		}
+
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// UseTOTPStep фиксирует использованный временной шаг; false — код этого или более
+// позднего шага уже использовался (повтор).
+func (s *Storage) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&UserTOTP{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+
+	if result.Error != nil {
+		return false, fmt.Errorf("use totp step: %w", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *Storage) DeleteTOTP(ctx context.Context, userID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("delete recovery codes: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&UserTOTP{}).Error; err != nil {
+			return fmt.Errorf("delete totp: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// UseRecoveryCode погашает резервный код; false — кода нет или он уже использован.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+
+	if result.Error != nil {
+		return false, fmt.Errorf("use recovery code: %w", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	codes := make([]RecoveryCode, 0, len(codeHashes))
+	for _, h := range codeHashes {
+		codes = append(codes, RecoveryCode{UserID: userID, CodeHash: h})
+	}
+
+	if len(codes) > 0 {
+		if err := tx.Omit("id").Create(&codes).Error; err != nil {
+			return fmt.Errorf("save recovery codes: %w", err)
+		}
+	}
+
+	return nil
+}
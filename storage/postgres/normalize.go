@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// e164Pattern — номера, уже записанные в E.164; их NormalizePhones не трогает.
+const e164Pattern = `^\+[1-9][0-9]{1,14}$`
+
+type PhoneBackfillResult struct {
+	Updated   int
+	Conflicts int
+	Invalid   int
+}
+
+// NormalizePhones переписывает номера, сохранённые до нормализации логинов, в E.164.
+// Номер, который после нормализации совпал с чужим, остаётся как есть, но теряет подтверждение:
+// по нему нельзя войти, пока конфликт не разберёт поддержка. Неразборчивые номера не меняются.
+// Повторный запуск ничего не делает.
+func (s *Storage) NormalizePhones(ctx context.Context, normalize func(string) (string, error)) (PhoneBackfillResult, error) {
+	var result PhoneBackfillResult
+
+	var users []User
+	err := s.db.WithContext(ctx).
+		Select("id", "phone").
+		Where("phone IS NOT NULL AND phone_verified_at IS NOT NULL AND phone !~ ?", e164Pattern).
+		Order("created_at, id").
+		Find(&users).Error
+	if err != nil {
+		return result, fmt.Errorf("list phones to normalize: %w", err)
+	}
+
+	for _, u := range users {
+		phone, err := normalize(*u.Phone)
+		if err != nil {
+			result.Invalid++
+			continue
+		}
+
+		err = s.db.WithContext(ctx).Model(&User{}).Where("id = ?", u.ID).Update("phone", phone).Error
+		if err == nil {
+			result.Updated++
+			continue
+		}
+		if !errors.Is(uniqueViolation(err), ErrPhoneTaken) {
+			return result, fmt.Errorf("normalize phone: %w", err)
+		}
+
+		err = s.db.WithContext(ctx).Model(&User{}).Where("id = ?", u.ID).Update("phone_verified_at", nil).Error
+		if err != nil {
+			return result, fmt.Errorf("mark phone conflict: %w", err)
+		}
+		result.Conflicts++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxEvent struct {
+	ID           string     `gorm:"column:id;primaryKey;default:gen_random_uuid()"`
+	Type         string     `gorm:"column:type;not null"`
+	Payload      string     `gorm:"column:payload;type:jsonb;not null"`
+	CreatedAt    time.Time  `gorm:"column:created_at;<-:false"`
+	DispatchedAt *time.Time `gorm:"column:dispatched_at"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+type WebhookDelivery struct {
+	EventID       string     `gorm:"column:event_id;primaryKey"`
+	Subscriber    string     `gorm:"column:subscriber;primaryKey"`
+	Attempts      int        `gorm:"column:attempts;not null"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;not null"`
+	DeliveredAt   *time.Time `gorm:"column:delivered_at"`
+	GaveUpAt      *time.Time `gorm:"column:gave_up_at"`
+	LastError     string     `gorm:"column:last_error;not null"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+type WebhookAttempt struct {
+	ID         string    `gorm:"column:id;primaryKey;default:gen_random_uuid()"`
+	EventID    string    `gorm:"column:event_id;not null"`
+	Subscriber string    `gorm:"column:subscriber;not null"`
+	URL        string    `gorm:"column:url;not null"`
+	Attempt    int       `gorm:"column:attempt;not null"`
+	StatusCode int       `gorm:"column:status_code;not null"`
+	Error      string    `gorm:"column:error;not null"`
+	DurationMS int64     `gorm:"column:duration_ms;not null"`
+	CreatedAt  time.Time `gorm:"column:created_at;<-:false"`
+}
+
+func (WebhookAttempt) TableName() string {
+	return "webhook_delivery_attempts"
+}
+
+// DueDelivery — доставка, которую пора выполнить, вместе с самим событием.
+type DueDelivery struct {
+	EventID    string
+	Subscriber string
+	Attempts   int
+	Type       string
+	Payload    string
+	CreatedAt  time.Time
+}
+
+// DeliveryOutcome: при Delivered и GaveUp следующей попытки не будет, иначе она назначается на NextAttemptAt.
+type DeliveryOutcome struct {
+	Delivered     bool
+	GaveUp        bool
+	NextAttemptAt time.Time
+}
+
+// AddOutboxEvent кладёт событие в outbox. Вызывается внутри InTx вместе с изменением,
+// о котором оно сообщает: событие публикуется тогда и только тогда, когда изменение зафиксировано.
+func (s *Storage) AddOutboxEvent(ctx context.Context, event *OutboxEvent) error {
+	if err := s.db.WithContext(ctx).Omit("id").Create(event).Error; err != nil {
+		return fmt.Errorf("add outbox event: %w", err)
+	}
+
+	return nil
+}
+
+// FanOutOutboxEvents заводит по доставке на каждого подписчика для ещё не разосланных событий.
+// SKIP LOCKED позволяет запускать несколько диспетчеров одновременно.
+func (s *Storage) FanOutOutboxEvents(ctx context.Context, limit int, subscribers func(eventType string) []string) (int, error) {
+	var n int
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []OutboxEvent
+
+		err := tx.Raw(
+			"SELECT id, type FROM outbox_events WHERE dispatched_at IS NULL ORDER BY created_at LIMIT ? FOR UPDATE SKIP LOCKED",
+			limit,
+		).Scan(&events).Error
+		if err != nil {
+			return fmt.Errorf("select outbox events: %w", err)
+		}
+
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(events))
+		var deliveries []WebhookDelivery
+		now := time.Now()
+
+		for _, e := range events {
+			ids = append(ids, e.ID)
+			for _, name := range subscribers(e.Type) {
+				deliveries = append(deliveries, WebhookDelivery{EventID: e.ID, Subscriber: name, NextAttemptAt: now})
+			}
+		}
+
+		if len(deliveries) > 0 {
+			if err := tx.Create(&deliveries).Error; err != nil {
+				return fmt.Errorf("create webhook deliveries: %w", err)
+			}
+		}
+
+		err = tx.Model(&OutboxEvent{}).
+			Where("id IN ?", ids).
+			Update("dispatched_at", now).Error
+		if err != nil {
+			return fmt.Errorf("mark outbox events dispatched: %w", err)
+		}
+
+		n = len(events)
+		return nil
+	})
+
+	return n, err
+}
+
+// ClaimDueDeliveries забирает доставки, срок которых наступил, и сдвигает их next_attempt_at
+// на lease: если процесс упадёт посреди отправки, доставка вернётся в очередь после аренды.
+func (s *Storage) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]DueDelivery, error) {
+	var due []DueDelivery
+
+	err := s.db.WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = ?
+		FROM outbox_events e
+		WHERE e.id = d.event_id AND (d.event_id, d.subscriber) IN (
+			SELECT event_id, subscriber FROM webhook_deliveries
+			WHERE delivered_at IS NULL AND gave_up_at IS NULL AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.event_id, d.subscriber, d.attempts, e.type, e.payload, e.created_at`,
+		now.Add(lease), now, limit,
+	).Scan(&due).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	return due, nil
+}
+
+// RecordDeliveryAttempt пишет попытку в журнал доставки и обновляет состояние доставки.
+func (s *Storage) RecordDeliveryAttempt(ctx context.Context, attempt *WebhookAttempt, outcome DeliveryOutcome) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("id").Create(attempt).Error; err != nil {
+			return fmt.Errorf("record webhook attempt: %w", err)
+		}
+
+		updates := map[string]any{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": attempt.Error,
+		}
+		switch {
+		case outcome.Delivered:
+			updates["delivered_at"] = time.Now()
+		case outcome.GaveUp:
+			updates["gave_up_at"] = time.Now()
+		default:
+			updates["next_attempt_at"] = outcome.NextAttemptAt
+		}
+
+		err := tx.Model(&WebhookDelivery{}).
+			Where("event_id = ? AND subscriber = ?", attempt.EventID, attempt.Subscriber).
+			Updates(updates).Error
+		if err != nil {
+			return fmt.Errorf("update webhook delivery: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ListWebhookAttempts — журнал доставки подписчика от новых попыток к старым; eventID необязателен.
+func (s *Storage) ListWebhookAttempts(ctx context.Context, subscriber, eventID string, limit int) ([]WebhookAttempt, error) {
+	query := s.db.WithContext(ctx).Where("subscriber = ?", subscriber)
+	if eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+
+	var attempts []WebhookAttempt
+	if err := query.Order("created_at DESC").Limit(limit).Find(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("list webhook attempts: %w", err)
+	}
+
+	return attempts, nil
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -19,8 +20,11 @@ type Storage struct {
 }
 
 type RefreshTokenData struct {
+	ID        string
 	UserID    string
 	ExpiresAt time.Time
+	AuthTime  time.Time
+	AMR       []string
 }
 
 type UserInfo struct {
@@ -31,9 +35,14 @@ type UserInfo struct {
 }
 
 type RefreshToken struct {
+	ID        string    `gorm:"column:id;not null"`
 	Token     string    `gorm:"primaryKey;column:token"`
 	UserID    string    `gorm:"column:user_id;not null"`
 	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;<-:false"`
+	// AuthTime и AMR — момент и способы исходного входа; при обновлении токенов не меняются
+	AuthTime time.Time `gorm:"column:auth_time;not null"`
+	AMR      string    `gorm:"column:amr;not null"`
 }
 
 func (RefreshToken) TableName() string {
@@ -41,18 +50,33 @@ func (RefreshToken) TableName() string {
 }
 
 type User struct {
-	ID           string  `gorm:"column:id;primaryKey"`
-	FirstName    string  `gorm:"column:first_name;not null"`
-	LastName     string  `gorm:"column:last_name;not null"`
-	Email        *string `gorm:"column:email"`
-	Phone        *string `gorm:"column:phone"`
-	PasswordHash string  `gorm:"column:password_hash;not null"`
+	ID              string     `gorm:"column:id;primaryKey"`
+	FirstName       string     `gorm:"column:first_name;not null"`
+	LastName        string     `gorm:"column:last_name;not null"`
+	Email           *string    `gorm:"column:email"`
+	Phone           *string    `gorm:"column:phone"`
+	EmailVerifiedAt *time.Time `gorm:"column:email_verified_at"`
+	PhoneVerifiedAt *time.Time `gorm:"column:phone_verified_at"`
+	PasswordHash    string     `gorm:"column:password_hash;not null"`
+	CreatedAt       time.Time  `gorm:"column:created_at;<-:false"`
+	Status          string     `gorm:"column:status;default:active"`
+	StatusReason    *string    `gorm:"column:status_reason"`
+	StatusChangedAt *time.Time `gorm:"column:status_changed_at"`
+	DeletedAt       *time.Time `gorm:"column:deleted_at"`
+	PurgeAfter      *time.Time `gorm:"column:purge_after"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
 
+// InTx выполняет fn в одной транзакции: все методы tx работают внутри неё.
+func (s *Storage) InTx(ctx context.Context, fn func(tx *Storage) error) error {
+	return s.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+		return fn(&Storage{db: db, log: s.log})
+	})
+}
+
 func New(cfg config.PostgresConfig, log *slog.Logger) (*Storage, error) {
 	op := "storage.postgres.New"
 
@@ -90,17 +114,25 @@ func New(cfg config.PostgresConfig, log *slog.Logger) (*Storage, error) {
 	}, nil
 }
 
+// SaveRefreshToken: sessionID сохраняется при ротации токена, чтобы сессию можно было
+// отозвать по идентификатору, даже если refresh-токен с тех пор обновлялся.
 func (s *Storage) SaveRefreshToken(
 	ctx context.Context,
+	sessionID string,
 	userID string,
 	token string,
 	expiresAt time.Time,
+	authTime time.Time,
+	amr []string,
 ) error {
 
 	rt := RefreshToken{
+		ID:        sessionID,
 		Token:     token,
 		UserID:    userID,
 		ExpiresAt: expiresAt,
+		AuthTime:  authTime,
+		AMR:       strings.Join(amr, ","),
 	}
 
 	if err := s.db.WithContext(ctx).Create(&rt).Error; err != nil {
@@ -128,9 +160,17 @@ func (s *Storage) GetRefreshToken(
 		return nil, fmt.Errorf("get refresh token: %w", err)
 	}
 
+	var amr []string
+	if rt.AMR != "" {
+		amr = strings.Split(rt.AMR, ",")
+	}
+
 	return &RefreshTokenData{
+		ID:        rt.ID,
 		UserID:    rt.UserID,
 		ExpiresAt: rt.ExpiresAt,
+		AuthTime:  rt.AuthTime,
+		AMR:       amr,
 	}, nil
 }
 
@@ -142,7 +182,8 @@ func (s *Storage) GetUserByLogin(
 	var user User
 
 	err := s.db.WithContext(ctx).
-		Where("email = ? OR phone = ?", login, login).
+		Where("(email = ? AND email_verified_at IS NOT NULL) OR (phone = ? AND phone_verified_at IS NOT NULL)", login, login).
+		Where("deleted_at IS NULL").
 		Limit(1).
 		First(&user).Error
 
@@ -181,6 +222,58 @@ func (s *Storage) DeleteRefreshToken(
 	return nil
 }
 
+func (s *Storage) DeleteUserRefreshTokens(
+	ctx context.Context,
+	userID string,
+) error {
+
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Delete(&RefreshToken{}).Error
+
+	if err != nil {
+		return fmt.Errorf("delete user refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUserRefreshTokenByID завершает одну сессию пользователя по её идентификатору.
+func (s *Storage) DeleteUserRefreshTokenByID(ctx context.Context, userID, id string) error {
+	result := s.db.WithContext(ctx).
+		Where("user_id = ? AND id = ?", userID, id).
+		Delete(&RefreshToken{})
+
+	if result.Error != nil {
+		return fmt.Errorf("delete refresh token: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) ListRefreshTokens(
+	ctx context.Context,
+	userID string,
+) ([]RefreshToken, error) {
+
+	var tokens []RefreshToken
+
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("list refresh tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
 func toNullString(s *string) sql.NullString {
 	if s == nil {
 		return sql.NullString{}
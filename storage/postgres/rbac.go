@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleNotAssigned = errors.New("role is not assigned to the user")
+)
+
+type Role struct {
+	Name        string `gorm:"column:name;primaryKey"`
+	Description string `gorm:"column:description;not null"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+type RolePermission struct {
+	Role       string `gorm:"column:role;primaryKey"`
+	Permission string `gorm:"column:permission;primaryKey"`
+}
+
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+type UserRole struct {
+	UserID    string    `gorm:"column:user_id;primaryKey"`
+	Role      string    `gorm:"column:role;primaryKey"`
+	GrantedAt time.Time `gorm:"column:granted_at;<-:false"`
+}
+
+func (UserRole) TableName() string {
+	return "user_roles"
+}
+
+// GetUserAccess возвращает роли пользователя и объединение их прав.
+func (s *Storage) GetUserAccess(ctx context.Context, userID string) ([]string, []string, error) {
+	var roles []string
+	err := s.db.WithContext(ctx).
+		Model(&UserRole{}).
+		Where("user_id = ?", userID).
+		Order("role").
+		Pluck("role", &roles).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("get user roles: %w", err)
+	}
+
+	var permissions []string
+	if len(roles) > 0 {
+		err = s.db.WithContext(ctx).
+			Model(&RolePermission{}).
+			Distinct("permission").
+			Where("role IN ?", roles).
+			Order("permission").
+			Pluck("permission", &permissions).Error
+		if err != nil {
+			return nil, nil, fmt.Errorf("get user permissions: %w", err)
+		}
+	}
+
+	return roles, permissions, nil
+}
+
+// AssignRole выдаёт роль; повторная выдача ничего не меняет.
+func (s *Storage) AssignRole(ctx context.Context, userID, role string) error {
+	err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserRole{UserID: userID, Role: role}).Error
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			switch pgErr.ConstraintName {
+			case "user_roles_role_fkey":
+				return ErrRoleNotFound
+			case "user_roles_user_id_fkey":
+				return ErrUserNotFound
+			}
+		}
+		return fmt.Errorf("assign role: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeRole(ctx context.Context, userID, role string) error {
+	result := s.db.WithContext(ctx).
+		Where("user_id = ? AND role = ?", userID, role).
+		Delete(&UserRole{})
+
+	if result.Error != nil {
+		return fmt.Errorf("revoke role: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrRoleNotAssigned
+	}
+
+	return nil
+}
+
+// ListRoles возвращает роли вместе с их правами.
+func (s *Storage) ListRoles(ctx context.Context) ([]Role, map[string][]string, error) {
+	var roles []Role
+	if err := s.db.WithContext(ctx).Order("name").Find(&roles).Error; err != nil {
+		return nil, nil, fmt.Errorf("list roles: %w", err)
+	}
+
+	var links []RolePermission
+	if err := s.db.WithContext(ctx).Order("permission").Find(&links).Error; err != nil {
+		return nil, nil, fmt.Errorf("list role permissions: %w", err)
+	}
+
+	permissions := make(map[string][]string, len(roles))
+	for _, l := range links {
+		permissions[l.Role] = append(permissions[l.Role], l.Permission)
+	}
+
+	return roles, permissions, nil
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTrustedDeviceNotFound = errors.New("trusted device not found")
+	ErrSessionNotFound       = errors.New("session not found")
+)
+
+type TrustedDevice struct {
+	ID          string    `gorm:"column:id;primaryKey;default:gen_random_uuid()"`
+	UserID      string    `gorm:"column:user_id;not null"`
+	Fingerprint string    `gorm:"column:fingerprint;not null"`
+	UserAgent   string    `gorm:"column:user_agent;not null"`
+	IP          string    `gorm:"column:ip;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at;<-:false"`
+	LastSeenAt  time.Time `gorm:"column:last_seen_at;not null"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;not null"`
+}
+
+func (TrustedDevice) TableName() string {
+	return "trusted_devices"
+}
+
+func (s *Storage) CreateTrustedDevice(ctx context.Context, device *TrustedDevice) error {
+	if err := s.db.WithContext(ctx).Create(device).Error; err != nil {
+		return fmt.Errorf("create trusted device: %w", err)
+	}
+
+	return nil
+}
+
+// GetTrustedDevice возвращает только действующее (не истёкшее) устройство.
+func (s *Storage) GetTrustedDevice(ctx context.Context, id string) (*TrustedDevice, error) {
+	var device TrustedDevice
+
+	err := s.db.WithContext(ctx).
+		Where("id = ? AND expires_at > ?", id, time.Now()).
+		First(&device).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTrustedDeviceNotFound
+		}
+		return nil, fmt.Errorf("get trusted device: %w", err)
+	}
+
+	return &device, nil
+}
+
+func (s *Storage) TouchTrustedDevice(ctx context.Context, id, ip string) error {
+	err := s.db.WithContext(ctx).
+		Model(&TrustedDevice{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"last_seen_at": time.Now(),
+			"ip":           ip,
+		}).Error
+
+	if err != nil {
+		return fmt.Errorf("touch trusted device: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListTrustedDevices(ctx context.Context, userID string) ([]TrustedDevice, error) {
+	var devices []TrustedDevice
+
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&devices).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("list trusted devices: %w", err)
+	}
+
+	return devices, nil
+}
+
+func (s *Storage) DeleteTrustedDevice(ctx context.Context, userID, id string) error {
+	result := s.db.WithContext(ctx).
+		Where("user_id = ? AND id = ?", userID, id).
+		Delete(&TrustedDevice{})
+
+	if result.Error != nil {
+		return fmt.Errorf("delete trusted device: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrTrustedDeviceNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteUserTrustedDevices(ctx context.Context, userID string) error {
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Delete(&TrustedDevice{}).Error
+
+	if err != nil {
+		return fmt.Errorf("delete user trusted devices: %w", err)
+	}
+
+	return nil
+}
@@ -2,9 +2,14 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"regexp"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
 )
 
 func (s *Storage) CreateUserWithLogin(
@@ -14,26 +19,184 @@ func (s *Storage) CreateUserWithLogin(
 
 	var email *string
 	var phone *string
+	var emailVerifiedAt *time.Time
+	var phoneVerifiedAt *time.Time
+
+	// логин подтверждён кодом до создания пользователя
+	now := time.Now()
 
 	emailRegex := regexp.MustCompile(`^[\w._%+\-]+@[\w.\-]+\.[a-zA-Z]{2,}$`)
 	if emailRegex.MatchString(login) {
 		email = &login
+		emailVerifiedAt = &now
 	} else {
 		phone = &login
+		phoneVerifiedAt = &now
 	}
 
 	user := User{
-		ID:           uuid.New().String(),
-		FirstName:    firstName,
-		LastName:     lastName,
-		Email:        email,
-		Phone:        phone,
-		PasswordHash: passwordHash,
+		ID:              uuid.New().String(),
+		FirstName:       firstName,
+		LastName:        lastName,
+		Email:           email,
+		Phone:           phone,
+		EmailVerifiedAt: emailVerifiedAt,
+		PhoneVerifiedAt: phoneVerifiedAt,
+		PasswordHash:    passwordHash,
 	}
 
 	if err := s.db.WithContext(ctx).Create(&user).Error; err != nil {
+		if uerr := uniqueViolation(err); uerr != nil {
+			return "", uerr
+		}
 		return "", err
 	}
 
 	return user.ID, nil
 }
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email is already in use")
+	ErrPhoneTaken   = errors.New("phone is already in use")
+)
+
+func (s *Storage) GetUserByID(
+	ctx context.Context,
+	id string,
+) (*User, error) {
+
+	var user User
+
+	err := s.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&user).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	return &user, nil
+}
+
+// UpdateUserEmail и UpdateUserPhone вызываются только после подтверждения кодом,
+// поэтому сразу отмечают идентификатор подтверждённым.
+func (s *Storage) UpdateUserEmail(ctx context.Context, id, email string) error {
+	return s.updateUserColumn(ctx, id, "email", email)
+}
+
+func (s *Storage) UpdateUserPhone(ctx context.Context, id, phone string) error {
+	return s.updateUserColumn(ctx, id, "phone", phone)
+}
+
+func (s *Storage) updateUserColumn(ctx context.Context, id, column, value string) error {
+	result := s.db.WithContext(ctx).
+		Model(&User{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			column:                  value,
+			column + "_verified_at": time.Now(),
+		})
+
+	if result.Error != nil {
+		if err := uniqueViolation(result.Error); err != nil {
+			return err
+		}
+		return fmt.Errorf("update user %s: %w", column, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// uniqueViolation переводит нарушение UNIQUE (email, телефон, WebAuthn-ключ) в понятную ошибку.
+func uniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return nil
+	}
+
+	switch pgErr.ConstraintName {
+	case "users_email_key", "users_email_lower_key":
+		return ErrEmailTaken
+	case "users_phone_key":
+		return ErrPhoneTaken
+	case "webauthn_credentials_credential_id_key":
+		return ErrCredentialExists
+	}
+
+	return nil
+}
+
+// SoftDeleteUser помечает удалённым активного пользователя и завершает все его сессии.
+// Строка физически удаляется позже в PurgeDeletedUsers.
+func (s *Storage) SoftDeleteUser(ctx context.Context, id string, purgeAfter time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("id = ? AND status = ?", id, UserStatusActive).
+			Updates(map[string]any{
+				"deleted_at":        time.Now(),
+				"purge_after":       purgeAfter,
+				"status":            UserStatusPendingDeletion,
+				"status_changed_at": time.Now(),
+			})
+
+		if result.Error != nil {
+			return fmt.Errorf("soft delete user: %w", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			var n int64
+			if err := tx.Model(&User{}).Where("id = ? AND deleted_at IS NULL", id).Count(&n).Error; err != nil {
+				return fmt.Errorf("soft delete user: %w", err)
+			}
+			if n > 0 {
+				return ErrStatusTransition
+			}
+			return ErrUserNotFound
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&RefreshToken{}).Error; err != nil {
+			return fmt.Errorf("delete user sessions: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// PurgeDeletedUsers окончательно удаляет пользователей с истёкшим сроком ожидания;
+// refresh_tokens удаляются каскадно.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, now time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("purge_after IS NOT NULL AND purge_after <= ?", now).
+		Delete(&User{})
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("purge deleted users: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (s *Storage) UpdateUserPassword(ctx context.Context, id, passwordHash string) error {
+	result := s.db.WithContext(ctx).
+		Model(&User{}).
+		Where("id = ?", id).
+		Update("password_hash", passwordHash)
+
+	if result.Error != nil {
+		return fmt.Errorf("update user password: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCredentialNotFound = errors.New("webauthn credential not found")
+	ErrCredentialExists   = errors.New("webauthn credential already registered")
+)
+
+// WebAuthnCredential: Credential — сериализованный webauthn.Credential (JSON),
+// SignCount вынесен отдельно, чтобы обновлять его без перезаписи всей записи.
+type WebAuthnCredential struct {
+	ID           string     `gorm:"column:id;primaryKey;default:gen_random_uuid()"`
+	UserID       string     `gorm:"column:user_id;not null"`
+	CredentialID []byte     `gorm:"column:credential_id;not null"`
+	Name         string     `gorm:"column:name;not null"`
+	Credential   string     `gorm:"column:credential;type:jsonb;not null"`
+	SignCount    int64      `gorm:"column:sign_count;not null"`
+	CreatedAt    time.Time  `gorm:"column:created_at;<-:false"`
+	LastUsedAt   *time.Time `gorm:"column:last_used_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred *WebAuthnCredential) error {
+	err := s.db.WithContext(ctx).Omit("id").Create(cred).Error
+	if err != nil {
+		if uerr := uniqueViolation(err); uerr != nil {
+			return uerr
+		}
+		return fmt.Errorf("save webauthn credential: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListWebAuthnCredentials(ctx context.Context, userID string) ([]WebAuthnCredential, error) {
+	var creds []WebAuthnCredential
+
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&creds).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("list webauthn credentials: %w", err)
+	}
+
+	return creds, nil
+}
+
+func (s *Storage) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error) {
+	var cred WebAuthnCredential
+
+	err := s.db.WithContext(ctx).
+		Where("credential_id = ?", credentialID).
+		First(&cred).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCredentialNotFound
+		}
+		return nil, fmt.Errorf("get webauthn credential: %w", err)
+	}
+
+	return &cred, nil
+}
+
+// UpdateWebAuthnCredential сохраняет новый счётчик подписей и флаги после успешного входа.
+func (s *Storage) UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, signCount int64, credential string) error {
+	err := s.db.WithContext(ctx).
+		Model(&WebAuthnCredential{}).
+		Where("credential_id = ?", credentialID).
+		Updates(map[string]any{
+			"sign_count":   signCount,
+			"credential":   credential,
+			"last_used_at": time.Now(),
+		}).Error
+
+	if err != nil {
+		return fmt.Errorf("update webauthn credential: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userID, id string) error {
+	result := s.db.WithContext(ctx).
+		Where("user_id = ? AND id = ?", userID, id).
+		Delete(&WebAuthnCredential{})
+
+	if result.Error != nil {
+		return fmt.Errorf("delete webauthn credential: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrCredentialNotFound
+	}
+
+	return nil
+}